@@ -0,0 +1,73 @@
+package main
+
+import "math/rand"
+
+// Binary RLNC over GF(2): every coefficient is a single bit, so vectors
+// are packed 64 per uint64 word and mixing is plain XOR of the selected
+// chunks. Cheap enough for constrained devices, at the cost of a much
+// higher chance that a random combination is not innovative.
+
+// packedWords is the number of uint64 words needed for k coefficient bits.
+func packedWords(n int) int {
+	return (n + 63) / 64
+}
+
+func bitAt(v []uint64, i int) uint16 {
+	return uint16(v[i/64] >> (i % 64) & 1)
+}
+
+// mixBinary returns a random GF(2) combination of src[0:k].
+func mixBinary(src []Symbol, c *Coding, rng *rand.Rand) Symbol {
+	k := c.K
+	vec := make([]uint64, packedWords(k))
+	for w := range vec {
+		vec[w] = rng.Uint64()
+	}
+	if k%64 != 0 {
+		vec[len(vec)-1] &= 1<<(k%64) - 1
+	}
+
+	// Ensure at least one non-zero coefficient
+	nonZero := false
+	for _, w := range vec {
+		if w != 0 {
+			nonZero = true
+			break
+		}
+	}
+	if !nonZero {
+		i := rng.Intn(k)
+		vec[i/64] |= 1 << (i % 64)
+	}
+
+	data := make([]byte, c.Size)
+	for i := 0; i < k; i++ {
+		if bitAt(vec, i) != 0 {
+			for j := range data {
+				data[j] ^= src[i].Data[j]
+			}
+		}
+	}
+	return Symbol{Bits: vec, Data: data}
+}
+
+// recodeBinary XORs a random non-empty subset of the held symbols,
+// coefficient bits and payloads alike.
+func recodeBinary(held []*Symbol, c *Coding, rng *rand.Rand) Symbol {
+	vec := make([]uint64, packedWords(c.K))
+	data := make([]byte, c.Size)
+	picked := false
+	for i, s := range held {
+		if rng.Intn(2) == 0 && !(i == len(held)-1 && !picked) {
+			continue
+		}
+		picked = true
+		for w := range vec {
+			vec[w] ^= s.Bits[w]
+		}
+		for j := range data {
+			data[j] ^= s.Data[j]
+		}
+	}
+	return Symbol{Bits: vec, Data: data}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Channel decides, packet by packet, whether a link loses what is sent
+// over it. Implementations may keep state (e.g. a burst in progress), so
+// every link gets its own instance.
+type Channel interface {
+	Drop(rng *rand.Rand) bool
+	// MeanLoss is the long-run fraction of packets lost.
+	MeanLoss() float64
+}
+
+// Bernoulli loses each packet independently with probability P.
+type Bernoulli struct {
+	P float64
+}
+
+func (b *Bernoulli) Drop(rng *rand.Rand) bool {
+	return rng.Float64() < b.P
+}
+
+func (b *Bernoulli) MeanLoss() float64 {
+	return b.P
+}
+
+// GilbertElliott is a two-state Markov loss model. The channel moves from
+// the good to the bad state with probability P and back with probability
+// R before every packet, and loses the packet with LossGood or LossBad
+// depending on its state. Small P and R give long loss bursts.
+type GilbertElliott struct {
+	P, R              float64
+	LossGood, LossBad float64
+	bad               bool
+}
+
+func (g *GilbertElliott) Drop(rng *rand.Rand) bool {
+	if g.bad {
+		if rng.Float64() < g.R {
+			g.bad = false
+		}
+	} else if rng.Float64() < g.P {
+		g.bad = true
+	}
+	if g.bad {
+		return rng.Float64() < g.LossBad
+	}
+	return rng.Float64() < g.LossGood
+}
+
+func (g *GilbertElliott) MeanLoss() float64 {
+	if g.P+g.R == 0 {
+		return g.LossGood
+	}
+	piBad := g.P / (g.P + g.R)
+	return piBad*g.LossBad + (1-piBad)*g.LossGood
+}
+
+// parseChannel turns a channel spec into a constructor for fresh
+// per-link instances. Accepted specs:
+//
+//	""  or "bernoulli"         independent loss with probability loss, in [0,1]
+//	"bernoulli:P"              independent loss with probability P
+//	"ge:P,R"                   Gilbert–Elliott, lossless good / lossy bad state
+//	"ge:P,R,LossGood,LossBad"  Gilbert–Elliott with explicit per-state loss
+func parseChannel(spec string, loss float64) (func() Channel, error) {
+	kind, args, _ := strings.Cut(spec, ":")
+	var vals []float64
+	if args != "" {
+		for _, a := range strings.Split(args, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+			if err != nil || !(v >= 0 && v <= 1) {
+				return nil, fmt.Errorf("channel %q: %q is not a probability", spec, a)
+			}
+			vals = append(vals, v)
+		}
+	}
+	switch kind {
+	case "", "bernoulli":
+		if len(vals) > 1 {
+			return nil, fmt.Errorf("channel %q: want bernoulli:P", spec)
+		}
+		if len(vals) == 1 {
+			loss = vals[0]
+		} else if err := checkLoss(loss); err != nil {
+			return nil, fmt.Errorf("-loss: %w", err)
+		}
+		return func() Channel { return &Bernoulli{P: loss} }, nil
+	case "ge":
+		switch len(vals) {
+		case 2:
+			vals = append(vals, 0, 1)
+		case 4:
+		default:
+			return nil, fmt.Errorf("channel %q: want ge:P,R or ge:P,R,LossGood,LossBad", spec)
+		}
+		return func() Channel {
+			return &GilbertElliott{P: vals[0], R: vals[1], LossGood: vals[2], LossBad: vals[3]}
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown channel model %q (want bernoulli or ge)", kind)
+}
+
+// channelName is the display form of a channel spec.
+func channelName(spec string) string {
+	if spec == "" {
+		return "bernoulli"
+	}
+	return spec
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// dropRate is the fraction of n packets ch loses.
+func dropRate(ch Channel, n int, rng *rand.Rand) float64 {
+	lost := 0
+	for i := 0; i < n; i++ {
+		if ch.Drop(rng) {
+			lost++
+		}
+	}
+	return float64(lost) / float64(n)
+}
+
+// TestChannelLossRate checks each model's long-run loss against the
+// stationary value, P/(P+R)·LossBad + R/(P+R)·LossGood for Gilbert–Elliott.
+func TestChannelLossRate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 500000
+	for _, tc := range []struct {
+		spec string
+		loss float64 // the -loss flag
+		want float64
+	}{
+		{"", 0, 0},
+		{"", 0.1, 0.1},
+		{"bernoulli", 0.3, 0.3},
+		{"bernoulli:0.05", 0.3, 0.05},
+		{"bernoulli:1", 0, 1},
+		{"ge:0.02,0.2", 0, 0.02 / 0.22},
+		{"ge:0.05,0.1,0.01,0.8", 0, 0.05/0.15*0.8 + 0.1/0.15*0.01},
+		{"ge:0.01,0.05,0.02,0.5", 0.9, 0.01/0.06*0.5 + 0.05/0.06*0.02},
+		{"ge:0,0.5,0.1,1", 0, 0.1}, // never leaves the good state
+	} {
+		newChannel, err := parseChannel(tc.spec, tc.loss)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.spec, err)
+		}
+		ch := newChannel()
+		if math.Abs(ch.MeanLoss()-tc.want) > 1e-12 {
+			t.Errorf("%q with -loss %v: MeanLoss %v, want %v", tc.spec, tc.loss, ch.MeanLoss(), tc.want)
+		}
+		if got := dropRate(ch, n, rng); math.Abs(got-tc.want) > 0.01 {
+			t.Errorf("%q with -loss %v: lost %.4f of %d packets, want %.4f", tc.spec, tc.loss, got, n, tc.want)
+		}
+	}
+}
+
+// TestChannelBursts checks that Gilbert–Elliott losses come in runs of
+// mean length 1/R when the bad state loses everything, and that every
+// link's instance keeps its own state.
+func TestChannelBursts(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	newChannel, _ := parseChannel("ge:0.02,0.2", 0)
+	ch := newChannel()
+	runs, lost := 0, 0
+	prev := false
+	for i := 0; i < 500000; i++ {
+		drop := ch.Drop(rng)
+		if drop {
+			lost++
+			if !prev {
+				runs++
+			}
+		}
+		prev = drop
+	}
+	if mean := float64(lost) / float64(runs); math.Abs(mean-5) > 0.25 {
+		t.Errorf("mean burst %.2f packets, want 5", mean)
+	}
+
+	a, b := newChannel().(*GilbertElliott), newChannel().(*GilbertElliott)
+	a.bad = true
+	if b.bad {
+		t.Error("channel instances share state")
+	}
+}
+
+func TestChannelRejects(t *testing.T) {
+	for _, spec := range []string{"bernoulli:1.5", "bernoulli:-0.1", "bernoulli:0.1,0.2", "bernoulli:x",
+		"ge:0.1", "ge:0.1,0.2,0.3", "ge:0.1,0.2,0.3,0.4,0.5", "ge:0.1,NaN", "gilbert:0.1,0.2"} {
+		if _, err := parseChannel(spec, 0); err == nil {
+			t.Errorf("%q accepted", spec)
+		}
+	}
+	for _, loss := range []float64{-0.1, 1.1, math.NaN()} {
+		if _, err := parseChannel("", loss); err == nil {
+			t.Errorf("-loss %v accepted", loss)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// The dashboard (-serve) streams a gossip run to a browser: a run message
+// with the topology, then batches of events as the paced simulator
+// produces them, then a result message with each peer's decode outcome.
+// A browser that connects mid-run first gets the run message and the
+// current ranks, so it can pick up from there.
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// Event is one thing that happened to a message or a peer, at virtual
+// time T in milliseconds. Kinds: "sent" and "lost" (a message from From
+// to Peer, when it leaves From), "innovative", "duplicate" and
+// "dependent" (a message reaching Peer), "complete" (Peer holds the whole
+// file). From is Peer for the last four. Rank is From's rank at the time.
+type Event struct {
+	T    float64 `json:"t"`
+	Kind string  `json:"kind"`
+	From int     `json:"from"`
+	Peer int     `json:"peer"`
+	Rank int     `json:"rank"`
+}
+
+// dashFlush is how often queued events go out to the browsers.
+const dashFlush = 50 * time.Millisecond
+
+// clientQueue is how many messages a browser may fall behind before it is
+// dropped.
+const clientQueue = 64
+
+// client is one connected browser. Its own goroutine writes the queued
+// messages, so nothing holding Dashboard.mu waits on a socket.
+type client struct {
+	conn *websocket.Conn
+	send chan any
+}
+
+// write sends queued messages until the queue is closed or a write fails,
+// then closes the connection, which also ends serveWS's read loop.
+func (c *client) write() {
+	defer c.conn.Close()
+	for msg := range c.send {
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		if err := c.conn.WriteJSON(msg); err != nil {
+			return
+		}
+	}
+}
+
+// Dashboard serves the page and fans events out to every WebSocket
+// client. Emit is called from the simulator goroutine and never waits on
+// the network.
+type Dashboard struct {
+	addr     string
+	speed    float64 // virtual time per wall-clock time, see Sim.Pace
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	clients  map[*client]bool
+	joined   chan struct{} // closed when the first client connects
+	run      map[string]any
+	ranks    []int
+	complete []bool
+	pending  []Event
+}
+
+// NewDashboard starts serving on addr, e.g. ":8080".
+func NewDashboard(addr string, speed float64) (*Dashboard, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	d := &Dashboard{addr: ln.Addr().String(), speed: speed, clients: make(map[*client]bool), joined: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardPage)
+	})
+	mux.HandleFunc("/ws", d.serveWS)
+	go http.Serve(ln, mux)
+	go d.flushLoop()
+	return d, nil
+}
+
+// URL is where to point the browser.
+func (d *Dashboard) URL() string {
+	host, port, _ := net.SplitHostPort(d.addr)
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
+
+// WaitClient blocks until a browser has connected.
+func (d *Dashboard) WaitClient() {
+	<-d.joined
+}
+
+func (d *Dashboard) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := d.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &client{conn: conn, send: make(chan any, clientQueue)}
+	d.mu.Lock()
+	if d.run != nil {
+		c.send <- d.run
+		c.send <- d.state()
+	}
+	d.clients[c] = true
+	if len(d.clients) == 1 {
+		select {
+		case <-d.joined:
+		default:
+			close(d.joined)
+		}
+	}
+	d.mu.Unlock()
+	go c.write()
+	// The page never sends anything; reading only notices it leaving, or
+	// write giving up on it
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	d.mu.Lock()
+	d.drop(c)
+	d.mu.Unlock()
+}
+
+// drop disconnects c if it is still connected; d.mu must be held.
+func (d *Dashboard) drop(c *client) {
+	if d.clients[c] {
+		delete(d.clients, c)
+		close(c.send)
+	}
+}
+
+// broadcast queues msg for every client; d.mu must be held. A client
+// whose queue is full cannot keep up and is dropped.
+func (d *Dashboard) broadcast(msg any) {
+	for c := range d.clients {
+		select {
+		case c.send <- msg:
+		default:
+			d.drop(c)
+		}
+	}
+}
+
+func (d *Dashboard) flushLoop() {
+	for range time.Tick(dashFlush) {
+		d.mu.Lock()
+		d.flush()
+		d.mu.Unlock()
+	}
+}
+
+// flush sends the queued events; d.mu must be held.
+func (d *Dashboard) flush() {
+	if len(d.pending) == 0 {
+		return
+	}
+	d.broadcast(map[string]any{"type": "events", "events": d.pending})
+	d.pending = nil
+}
+
+// state is the current ranks, copied since Emit keeps updating them while
+// the message waits in a client's queue; d.mu must be held.
+func (d *Dashboard) state() map[string]any {
+	return map[string]any{"type": "state", "ranks": slices.Clone(d.ranks), "complete": slices.Clone(d.complete)}
+}
+
+// Start announces a new run of scheme name on graph, in which a peer
+// needs rank need to hold the file.
+func (d *Dashboard) Start(name string, graph *Graph, need int) {
+	names := make([]string, graph.n)
+	var edges [][2]int
+	for i := range names {
+		names[i] = graph.name(i)
+		for _, j := range graph.adj[i] {
+			edges = append(edges, [2]int{i, j})
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flush()
+	d.run = map[string]any{"type": "run", "scheme": name, "peers": names, "edges": edges, "need": need, "speed": d.speed}
+	d.ranks, d.complete = make([]int, graph.n), make([]bool, graph.n)
+	d.broadcast(d.run)
+}
+
+// Emit queues ev for the next flush.
+func (d *Dashboard) Emit(ev Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch ev.Kind {
+	case "innovative":
+		d.ranks[ev.Peer] = ev.Rank
+	case "complete":
+		d.complete[ev.Peer] = true
+	}
+	d.pending = append(d.pending, ev)
+}
+
+// Finish reports each receiver's decode outcome at the end of a run; the
+// source, peer 0, is shown as holding the file.
+func (d *Dashboard) Finish(decodes []decodeResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	decoded := make([]bool, len(d.ranks))
+	decoded[0] = true
+	for _, r := range decodes {
+		decoded[r.peer] = r.ok
+	}
+	d.flush()
+	d.broadcast(map[string]any{"type": "result", "decoded": decoded})
+}
+
+// Hold keeps serving the last run after the simulator is done.
+func (d *Dashboard) Hold() {
+	fmt.Printf("\nDashboard still serving at %s; Ctrl-C to exit\n", d.URL())
+	select {}
+}
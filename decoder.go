@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrRankDeficient = errors.New("decoder: not enough innovative symbols")
+
+// coeffAt returns coefficient i of the symbol, whichever representation
+// (dense or GF(2) bit-packed) it uses.
+func (s *Symbol) coeffAt(i int) uint16 {
+	if s.Bits != nil {
+		return bitAt(s.Bits, i)
+	}
+	return s.Coeff[i]
+}
+
+// RankDecoder is an online decoder: it tracks the rank as symbols arrive
+// and reports whether each one was innovative.
+type RankDecoder interface {
+	Add(sym *Symbol) bool
+	Rank() int
+	Complete() bool
+	Ops() int
+	Data() ([]byte, error)
+}
+
+// Decoder recovers the k source chunks from coded symbols by Gaussian
+// elimination over the GF.
+type Decoder struct {
+	gf   *GF
+	k    int
+	syms []*Symbol
+	ops  int
+}
+
+func NewDecoder(gf *GF, k int) *Decoder {
+	return &Decoder{gf: gf, k: k}
+}
+
+// Add queues a coded symbol for decoding. Symbols are not modified.
+func (d *Decoder) Add(sym *Symbol) {
+	d.syms = append(d.syms, sym)
+}
+
+// Decode runs Gauss-Jordan elimination on the queued symbols and returns
+// the k source chunks concatenated, or ErrRankDeficient if the symbols do
+// not span all k dimensions.
+func (d *Decoder) Decode() ([]byte, error) {
+	gf := d.gf
+	n := len(d.syms)
+	coeff := make([][]uint16, n)
+	data := make([][]byte, n)
+	for i, s := range d.syms {
+		coeff[i] = make([]uint16, d.k)
+		for j := range coeff[i] {
+			coeff[i][j] = s.coeffAt(j)
+		}
+		data[i] = append([]byte(nil), s.Data...)
+	}
+
+	for col := 0; col < d.k; col++ {
+		piv := -1
+		for r := col; r < n; r++ {
+			if coeff[r][col] != 0 {
+				piv = r
+				break
+			}
+		}
+		if piv < 0 {
+			return nil, fmt.Errorf("%w (rank < %d at column %d)", ErrRankDeficient, d.k, col)
+		}
+		coeff[col], coeff[piv] = coeff[piv], coeff[col]
+		data[col], data[piv] = data[piv], data[col]
+
+		// Normalise the pivot row, then clear the column everywhere else
+		if inv := gf.Inv(coeff[col][col]); inv != 1 {
+			for j := col; j < d.k; j++ {
+				coeff[col][j] = gf.Mul(coeff[col][j], inv)
+			}
+			gf.Scale(data[col], inv)
+			d.ops++
+		}
+		for r := 0; r < n; r++ {
+			if r == col || coeff[r][col] == 0 {
+				continue
+			}
+			c := coeff[r][col]
+			for j := col; j < d.k; j++ {
+				coeff[r][j] ^= gf.Mul(c, coeff[col][j])
+			}
+			gf.MulAdd(data[r], data[col], c)
+			d.ops++
+		}
+	}
+
+	out := make([]byte, 0, d.k*len(data[0]))
+	for i := 0; i < d.k; i++ {
+		out = append(out, data[i]...)
+	}
+	return out, nil
+}
+
+// Ops returns the number of payload row operations (scaling a chunk or
+// adding a multiple of one chunk to another) decoding has performed. It
+// is the decoding cost that systematic and sparse coding reduce.
+func (d *Decoder) Ops() int {
+	return d.ops
+}
+
+// ProgressiveDecoder keeps the received coefficient matrix in reduced
+// row-echelon form, so each arriving symbol is inserted in O(k^2) field
+// operations (plus the matching payload work) instead of re-running a
+// full rank computation. Source chunks become available as soon as their
+// pivot row reduces to a unit vector.
+type ProgressiveDecoder struct {
+	gf    *GF
+	k     int
+	coeff [][]uint16 // coeff[c] is the row whose pivot is column c, nil if none
+	data  [][]byte
+	rank  int
+	ops   int // payload row operations, see Decoder.Ops
+}
+
+func NewProgressiveDecoder(gf *GF, k int) *ProgressiveDecoder {
+	return &ProgressiveDecoder{
+		gf:    gf,
+		k:     k,
+		coeff: make([][]uint16, k),
+		data:  make([][]byte, k),
+	}
+}
+
+// Add inserts a symbol and reports whether it was innovative, i.e.
+// increased the rank. Non-innovative symbols are discarded.
+func (d *ProgressiveDecoder) Add(sym *Symbol) bool {
+	gf := d.gf
+	row := make([]uint16, d.k)
+	for j := range row {
+		row[j] = sym.coeffAt(j)
+	}
+
+	// Reduce the coefficients against the existing pivots. The payload
+	// only needs the same treatment if the symbol turns out innovative,
+	// so remember the factors instead of touching it now.
+	var factors []uint16
+	for c := 0; c < d.k; c++ {
+		if row[c] == 0 || d.coeff[c] == nil {
+			continue
+		}
+		f := row[c]
+		for j := c; j < d.k; j++ {
+			row[j] ^= gf.Mul(f, d.coeff[c][j])
+		}
+		factors = append(factors, uint16(c), f)
+	}
+
+	piv := -1
+	for c := 0; c < d.k; c++ {
+		if row[c] != 0 {
+			piv = c
+			break
+		}
+	}
+	if piv < 0 {
+		return false
+	}
+
+	data := append([]byte(nil), sym.Data...)
+	for i := 0; i < len(factors); i += 2 {
+		gf.MulAdd(data, d.data[factors[i]], factors[i+1])
+	}
+	d.ops += len(factors) / 2
+
+	if inv := gf.Inv(row[piv]); inv != 1 {
+		for j := piv; j < d.k; j++ {
+			row[j] = gf.Mul(row[j], inv)
+		}
+		gf.Scale(data, inv)
+		d.ops++
+	}
+
+	// Clear the new pivot column from the other rows to stay in RREF
+	for c := 0; c < d.k; c++ {
+		if d.coeff[c] == nil || d.coeff[c][piv] == 0 {
+			continue
+		}
+		f := d.coeff[c][piv]
+		for j := piv; j < d.k; j++ {
+			d.coeff[c][j] ^= gf.Mul(f, row[j])
+		}
+		gf.MulAdd(d.data[c], data, f)
+		d.ops++
+	}
+	d.coeff[piv] = row
+	d.data[piv] = data
+	d.rank++
+	return true
+}
+
+// Ops returns the number of payload row operations performed so far.
+func (d *ProgressiveDecoder) Ops() int {
+	return d.ops
+}
+
+func (d *ProgressiveDecoder) Rank() int {
+	return d.rank
+}
+
+func (d *ProgressiveDecoder) Complete() bool {
+	return d.rank == d.k
+}
+
+// IsDecoded reports whether source chunk i is already recovered, which
+// can happen well before full rank.
+func (d *ProgressiveDecoder) IsDecoded(i int) bool {
+	row := d.coeff[i]
+	if row == nil {
+		return false
+	}
+	for j := i + 1; j < d.k; j++ {
+		if row[j] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Decoded returns the indices of all source chunks recovered so far.
+func (d *ProgressiveDecoder) Decoded() []int {
+	var idx []int
+	for i := 0; i < d.k; i++ {
+		if d.IsDecoded(i) {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// Chunk returns source chunk i, or nil if it is not decoded yet.
+func (d *ProgressiveDecoder) Chunk(i int) []byte {
+	if !d.IsDecoded(i) {
+		return nil
+	}
+	return d.data[i]
+}
+
+// Data returns the k source chunks concatenated once the decoder is
+// complete, or ErrRankDeficient otherwise.
+func (d *ProgressiveDecoder) Data() ([]byte, error) {
+	if !d.Complete() {
+		return nil, fmt.Errorf("%w (rank %d/%d)", ErrRankDeficient, d.rank, d.k)
+	}
+	out := make([]byte, 0, d.k*len(d.data[0]))
+	for i := 0; i < d.k; i++ {
+		out = append(out, d.data[i]...)
+	}
+	return out, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// bruteRank is the rank of rows over gf by definition: the size of the
+// largest square submatrix with a non-zero determinant, each determinant
+// expanded over all permutations. Nothing in it shares code with the
+// decoders' elimination.
+func bruteRank(gf *GF, rows [][]uint16, k int) int {
+	for r := min(len(rows), k); r > 0; r-- {
+		found := false
+		subsets(len(rows), r, func(rs []int) {
+			subsets(k, r, func(cs []int) {
+				found = found || leibniz(gf, rows, rs, cs) != 0
+			})
+		})
+		if found {
+			return r
+		}
+	}
+	return 0
+}
+
+// subsets calls f with every increasing r-subset of 0..n-1.
+func subsets(n, r int, f func([]int)) {
+	idx := make([]int, 0, r)
+	var rec func(from int)
+	rec = func(from int) {
+		if len(idx) == r {
+			f(idx)
+			return
+		}
+		for i := from; i < n; i++ {
+			idx = append(idx, i)
+			rec(i + 1)
+			idx = idx[:len(idx)-1]
+		}
+	}
+	rec(0)
+}
+
+// leibniz is the determinant of rows[rs][cs]. In characteristic 2 every
+// sign is +1, so it is the sum over permutations of their products.
+func leibniz(gf *GF, rows [][]uint16, rs, cs []int) uint16 {
+	var det uint16
+	used := make([]bool, len(cs))
+	var rec func(i int, prod uint16)
+	rec = func(i int, prod uint16) {
+		if i == len(rs) {
+			det ^= prod
+			return
+		}
+		for j, c := range cs {
+			if !used[j] {
+				used[j] = true
+				rec(i+1, gf.Mul(prod, rows[rs[i]][c]))
+				used[j] = false
+			}
+		}
+	}
+	rec(0, 1)
+	return det
+}
+
+// rankRows draws n coefficient rows of length k that are often dependent:
+// some are combinations of earlier rows, and entries favour 0 and 1.
+func rankRows(gf *GF, n, k int, rng *rand.Rand) [][]uint16 {
+	rows := make([][]uint16, n)
+	for i := range rows {
+		rows[i] = make([]uint16, k)
+		if i > 0 && rng.Intn(3) == 0 {
+			for _, prev := range rows[:i] {
+				f := uint16(rng.Intn(gf.size))
+				for j := range rows[i] {
+					rows[i][j] ^= gf.Mul(f, prev[j])
+				}
+			}
+			continue
+		}
+		for j := range rows[i] {
+			switch rng.Intn(3) {
+			case 0:
+			case 1:
+				rows[i][j] = 1
+			default:
+				rows[i][j] = uint16(rng.Intn(gf.size))
+			}
+		}
+	}
+	return rows
+}
+
+// rowSymbol wraps a coefficient row as a symbol over c, bit-packed in GF(2).
+func rowSymbol(c *Coding, row []uint16, rng *rand.Rand) *Symbol {
+	s := &Symbol{Data: make([]byte, c.Size)}
+	rng.Read(s.Data)
+	if c.bits == 1 {
+		s.Bits = make([]uint64, packedWords(c.K))
+		for j, v := range row {
+			s.Bits[j/64] |= uint64(v) << (j % 64)
+		}
+		return s
+	}
+	s.Coeff = append([]uint16(nil), row...)
+	return s
+}
+
+func TestDecoderRank(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const k = 5
+	for _, bits := range []int{1, 8, 16} {
+		c, err := NewCoding(bits, 0, k, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoders := map[string]func() RankDecoder{
+			"progressive": func() RankDecoder { return NewProgressiveDecoder(c.GF, k) },
+			"sparse":      func() RankDecoder { return NewSparseDecoder(c.GF, k) },
+		}
+		for trial := 0; trial < 300; trial++ {
+			rows := rankRows(c.GF, 1+rng.Intn(7), k, rng)
+			for name, newDec := range decoders {
+				dec := newDec()
+				for i, row := range rows {
+					want := bruteRank(c.GF, rows[:i+1], k)
+					innovative := dec.Add(rowSymbol(c, row, rng))
+					if dec.Rank() != want {
+						t.Fatalf("GF(2^%d) %s: rank %d after %d rows, brute force says %d; rows %v",
+							bits, name, dec.Rank(), i+1, want, rows[:i+1])
+					}
+					if wantInnov := want > bruteRank(c.GF, rows[:i], k); innovative != wantInnov {
+						t.Fatalf("GF(2^%d) %s: row %d innovative = %v, want %v; rows %v",
+							bits, name, i, innovative, wantInnov, rows[:i+1])
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestDecoderDecode decodes random data from random mixes, and checks
+// that too few symbols, or many copies of one, are rank deficient.
+func TestDecoderDecode(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	const k = 16
+	for _, bits := range []int{1, 8, 16} {
+		c, err := NewCoding(bits, 0, k, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, syms := encodeFile(c, rng)
+
+		dec, copies := NewDecoder(c.GF, k), NewDecoder(c.GF, k)
+		for i := 0; i < k-1; i++ {
+			sym := mixSymbol(syms, c, rng)
+			dec.Add(&sym)
+			copies.Add(&sym)
+			copies.Add(&sym)
+		}
+		for name, d := range map[string]*Decoder{"k-1 symbols": dec, "repeated symbols": copies} {
+			if _, err := d.Decode(); !errors.Is(err, ErrRankDeficient) {
+				t.Fatalf("GF(2^%d) %s: error %v, want ErrRankDeficient", bits, name, err)
+			}
+		}
+
+		// A few extra mixes make rank k near certain, even in GF(2)
+		for i := 0; i < 9; i++ {
+			sym := mixSymbol(syms, c, rng)
+			dec.Add(&sym)
+		}
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("GF(2^%d): %v", bits, err)
+		}
+		if !bytes.Equal(got, src) {
+			t.Fatalf("GF(2^%d): decoded data differs from the source", bits)
+		}
+		// Decode leaves the queued symbols alone, so it can run again
+		if again, err := dec.Decode(); err != nil || !bytes.Equal(again, src) {
+			t.Fatalf("GF(2^%d): second decode differs: %v", bits, err)
+		}
+	}
+}
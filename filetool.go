@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The encode and decode subcommands turn the simulator's coder into a file
+// tool. A coded file is a header followed by symbol records, all integers
+// big-endian:
+//
+//	header: "RLNC" | version u8 | field bits u8 | poly u32 | k u32 |
+//	        symbol size u32 | file length u64 | SHA-256 of the file [32]
+//	record: length u32 | packet [length]
+//
+// Packets are the wire format of wire.go, so a record that fails its
+// CRC32C is skipped on its own. A container holds one header and many
+// records; in directory mode every symbol is its own file with its own
+// copy of the header, so any subset of files is still decodable.
+
+const (
+	fileMagic   = "RLNC"
+	fileVersion = 2
+
+	// maxFileSymbols bounds the source symbols of one coded file, its
+	// generations times k, since decode keeps per-symbol state for every
+	// generation it receives symbols of.
+	maxFileSymbols = 1 << 24
+)
+
+// fileHeader describes the original file and how it was coded.
+type fileHeader struct {
+	field, poly int
+	k, symSize  int
+	length      int64
+	sum         [sha256.Size]byte
+}
+
+func (h fileHeader) marshal() []byte {
+	b := []byte(fileMagic)
+	b = append(b, fileVersion, byte(h.field))
+	b = binary.BigEndian.AppendUint32(b, uint32(h.poly))
+	b = binary.BigEndian.AppendUint32(b, uint32(h.k))
+	b = binary.BigEndian.AppendUint32(b, uint32(h.symSize))
+	b = binary.BigEndian.AppendUint64(b, uint64(h.length))
+	return append(b, h.sum[:]...)
+}
+
+// readFileHeader reads and checks a header. Everything in it is checked
+// before the caller sizes anything from it: the field, a k and symbol
+// size a wire packet can carry, and a length whose generations hold at
+// most maxFileSymbols source symbols.
+func readFileHeader(r io.Reader) (fileHeader, error) {
+	var b [len(fileMagic) + 2 + 3*4 + 8 + sha256.Size]byte
+	if n, err := io.ReadFull(r, b[:]); err != nil {
+		if n == 0 {
+			return fileHeader{}, errors.New("empty file")
+		}
+		return fileHeader{}, errors.New("truncated header")
+	}
+	if string(b[:4]) != fileMagic {
+		return fileHeader{}, errors.New("not an RLNC coded file")
+	}
+	if b[4] != fileVersion {
+		return fileHeader{}, fmt.Errorf("unsupported version %d", b[4])
+	}
+	h := fileHeader{
+		field:   int(b[5]),
+		poly:    int(binary.BigEndian.Uint32(b[6:])),
+		k:       int(binary.BigEndian.Uint32(b[10:])),
+		symSize: int(binary.BigEndian.Uint32(b[14:])),
+		length:  int64(binary.BigEndian.Uint64(b[18:])),
+	}
+	copy(h.sum[:], b[26:])
+	if h.field != 1 && h.field != 8 && h.field != 16 {
+		return fileHeader{}, fmt.Errorf("corrupt header: field size %d bits", h.field)
+	}
+	if h.k < 1 || h.k > 0xffff || h.symSize < 1 || h.symSize > 0xffff {
+		return fileHeader{}, fmt.Errorf("corrupt header: k=%d, symbol size %d", h.k, h.symSize)
+	}
+	if h.length < 0 || fileSymbols(h.length, h.k, h.symSize) > maxFileSymbols {
+		return fileHeader{}, fmt.Errorf("corrupt header: length %d in generations of %d x %d B", h.length, h.k, h.symSize)
+	}
+	return h, nil
+}
+
+// fileSymbols is the number of source symbols, generations times k, that
+// coding length bytes takes.
+func fileSymbols(length int64, k, symSize int) int64 {
+	gens := int64(1)
+	if length > 0 {
+		gens = (length-1)/(int64(k)*int64(symSize)) + 1
+	}
+	return gens * int64(k)
+}
+
+// appendRecord appends sym as a length-prefixed packet.
+func appendRecord(b []byte, c *Coding, sym *Symbol) ([]byte, error) {
+	pkt, err := Marshal(sym, c)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(pkt)))
+	return append(b, pkt...), nil
+}
+
+// readRecord returns the next packet; io.EOF means there are no more.
+func readRecord(r io.Reader) ([]byte, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("truncated record")
+		}
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(n[:])
+	if size > maxPacketSize {
+		return nil, fmt.Errorf("corrupt record length %d", size)
+	}
+	pkt := make([]byte, size)
+	if _, err := io.ReadFull(r, pkt); err != nil {
+		return nil, errors.New("truncated record")
+	}
+	return pkt, nil
+}
+
+// runEncode implements "encode": it writes n coded symbols per generation
+// of the input file, to one container file or, if the output is a
+// directory, one file per symbol.
+func runEncode(args []string) error {
+	fs := flag.NewFlagSet("encode", flag.ExitOnError)
+	in := fs.String("in", "", "File to encode (- for stdin)")
+	out := fs.String("out", "", "Output container file, or a directory (existing or ending in /) for one file per symbol")
+	n := fs.Int("n", 0, "Coded symbols per generation (default 1.5k)")
+	genSize := fs.Int("k", defaultK, "Generation size: source symbols per generation")
+	symSize := fs.Int("symsize", defaultSize, "Symbol payload size in bytes")
+	fieldBits := fs.Int("field", 8, "Number of bits for Galois Field (1, 8 or 16)")
+	poly := fs.Int("poly", 0, "Primitive polynomial for the Galois Field (0 = default for -field)")
+	seed := fs.Int64("seed", 0, "Random seed for the coefficients (0 = derive from the current time)")
+	fs.Parse(args)
+
+	if *in == "" || *out == "" {
+		return errors.New("encode needs -in and -out")
+	}
+	c, err := NewCoding(*fieldBits, *poly, *genSize, *symSize)
+	if err != nil {
+		return err
+	}
+	if *n == 0 {
+		*n = c.K + c.K/2
+	}
+	if c.K > 0xffff || c.Size > 0xffff {
+		return errors.New("the wire format holds -k and -symsize up to 65535")
+	}
+	if *n < c.K {
+		return fmt.Errorf("-n %d is below k=%d; no subset could decode", *n, c.K)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	if n := fileSymbols(int64(len(data)), c.K, c.Size); n > maxFileSymbols {
+		return fmt.Errorf("input needs %d source symbols, more than %d; raise -symsize", n, maxFileSymbols)
+	}
+	hdr := fileHeader{field: c.bits, poly: c.poly, k: c.K, symSize: c.Size, length: int64(len(data)), sum: sha256.Sum256(data)}
+	gens := c.splitGenerations(data)
+
+	dir := strings.HasSuffix(*out, "/")
+	if fi, err := os.Stat(*out); err == nil && fi.IsDir() {
+		dir = true
+	}
+	var w *bufio.Writer
+	if dir {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			return err
+		}
+	} else {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = bufio.NewWriter(f)
+		w.Write(hdr.marshal())
+	}
+
+	for g, src := range gens {
+		for i := 0; i < *n; i++ {
+			sym := mixSymbol(src, c, rng)
+			sym.Gen = g
+			var prefix []byte
+			if dir {
+				prefix = hdr.marshal()
+			}
+			rec, err := appendRecord(prefix, c, &sym)
+			if err != nil {
+				return err
+			}
+			if !dir {
+				w.Write(rec)
+				continue
+			}
+			name := filepath.Join(*out, fmt.Sprintf("sym-%05d-%05d.rlnc", g, i))
+			if err := os.WriteFile(name, rec, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	if !dir {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("encoded %d B into %d generations x %d symbols (k=%d, %d B, GF(2^%d), seed %d) -> %s\n",
+		len(data), len(gens), *n, c.K, c.Size, c.bits, *seed, *out)
+	return nil
+}
+
+// runDecode implements "decode": it reads symbols from any mix of
+// container files, symbol files and directories of them, in any order and
+// with duplicates, and reconstructs the original file once every
+// generation is at full rank.
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	out := fs.String("out", "", "Where to write the reconstructed file (- for stdout)")
+	fs.Parse(args)
+	if *out == "" || fs.NArg() == 0 {
+		return errors.New("usage: decode -out FILE INPUT...")
+	}
+
+	var paths []string
+	for _, p := range fs.Args() {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			paths = append(paths, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Type().IsRegular() {
+				paths = append(paths, filepath.Join(p, e.Name()))
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	var (
+		hdr                      *fileHeader
+		c                        *Coding
+		dec                      *GenerationDecoder
+		read, redundant, corrupt int
+	)
+	for _, p := range paths {
+		if dec != nil && dec.Complete() {
+			break
+		}
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		r := bytes.NewReader(raw)
+		h, err := readFileHeader(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if hdr == nil {
+			hdr = &h
+			if c, err = NewCoding(h.field, h.poly, h.k, h.symSize); err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+			dec = NewGenerationDecoder(c.generationCount(int(h.length)), func() RankDecoder { return NewProgressiveDecoder(c.GF, c.K) })
+		} else if h != *hdr {
+			return fmt.Errorf("%s: symbols belong to a different file", p)
+		}
+		for !dec.Complete() {
+			pkt, err := readRecord(r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+			sym, err := unmarshalFor(pkt, c, dec.Generations())
+			if err != nil {
+				corrupt++
+				continue
+			}
+			read++
+			if !dec.Add(&sym) {
+				redundant++
+			}
+		}
+	}
+	if dec == nil {
+		return errors.New("no symbols found")
+	}
+	if !dec.Complete() {
+		return fmt.Errorf("not enough symbols: %d/%d generations at full rank (%d symbols read, %d redundant, %d corrupt)",
+			dec.Done(), dec.Generations(), read, redundant, corrupt)
+	}
+	data, err := dec.Data()
+	if err != nil {
+		return err
+	}
+	data = data[:hdr.length]
+	if sha256.Sum256(data) != hdr.sum {
+		return errors.New("checksum mismatch: reconstructed file is corrupt")
+	}
+	if *out == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("decoded %d B from %d symbols (%d redundant, %d corrupt skipped), checksum OK -> %s\n",
+		len(data), read, redundant, corrupt, *out)
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFileRoundTrip encodes inputs spanning several generations into a
+// container and into a directory, damages the coded copies, and decodes
+// them back.
+func TestFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, size := range []int{0, 1, 5000} {
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(data)
+		in := filepath.Join(dir, "in")
+		if err := os.WriteFile(in, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range []string{"1", "8", "16"} {
+			container := filepath.Join(dir, "coded.rlnc")
+			symbols := filepath.Join(dir, "symbols") + "/"
+			for _, out := range []string{container, symbols} {
+				os.RemoveAll(out)
+				if err := runEncode([]string{"-in", in, "-out", out, "-k", "8", "-symsize", "256", "-n", "24", "-field", field, "-seed", "1"}); err != nil {
+					t.Fatalf("%d B, GF(2^%s): encode: %v", size, field, err)
+				}
+			}
+
+			// A flipped byte mid-container only costs the symbol it lands in
+			raw, err := os.ReadFile(container)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw[len(raw)/2] ^= 0xff
+			os.WriteFile(container, raw, 0o644)
+			// Dropping half the symbol files still leaves k per generation
+			files, _ := filepath.Glob(symbols + "*")
+			for _, f := range files {
+				if strings.HasSuffix(f, "1.rlnc") || strings.HasSuffix(f, "3.rlnc") || strings.HasSuffix(f, "5.rlnc") {
+					os.Remove(f)
+				}
+			}
+
+			for _, coded := range []string{container, symbols} {
+				out := filepath.Join(dir, "out")
+				if err := runDecode([]string{"-out", out, coded}); err != nil {
+					t.Fatalf("%d B, GF(2^%s), %s: decode: %v", size, field, filepath.Base(coded), err)
+				}
+				got, _ := os.ReadFile(out)
+				if !bytes.Equal(got, data) {
+					t.Errorf("%d B, GF(2^%s), %s: decoded file differs", size, field, filepath.Base(coded))
+				}
+			}
+		}
+	}
+}
+
+func TestFileHeaderRejects(t *testing.T) {
+	good := fileHeader{field: 8, poly: 0x11d, k: 64, symSize: 1024, length: 100000, sum: sha256.Sum256(nil)}.marshal()
+	if _, err := readFileHeader(bytes.NewReader(good)); err != nil {
+		t.Fatalf("valid header: %v", err)
+	}
+	with := func(off int, put func([]byte)) []byte {
+		b := append([]byte(nil), good...)
+		put(b[off:])
+		return b
+	}
+	cases := map[string][]byte{
+		"empty":     nil,
+		"truncated": good[:len(good)-1],
+		"magic":     with(0, func(b []byte) { b[0] = 'X' }),
+		"version":   with(4, func(b []byte) { b[0] = 9 }),
+		"field":     with(5, func(b []byte) { b[0] = 7 }),
+		"k=0":       with(10, func(b []byte) { binary.BigEndian.PutUint32(b, 0) }),
+		"k>65535":   with(10, func(b []byte) { binary.BigEndian.PutUint32(b, 1<<16) }),
+		"size=0":    with(14, func(b []byte) { binary.BigEndian.PutUint32(b, 0) }),
+		"negative":  with(18, func(b []byte) { binary.BigEndian.PutUint64(b, 1<<63) }),
+		// Within k*size*2^20 bytes, but 2^20 generations of k=65535
+		"symbols": fileHeader{field: 8, poly: 0x11d, k: 0xffff, symSize: 1, length: 0xffff << 20}.marshal(),
+	}
+	for name, b := range cases {
+		if _, err := readFileHeader(bytes.NewReader(b)); err == nil {
+			t.Errorf("%s: header accepted", name)
+		}
+	}
+
+	dir := t.TempDir()
+	for name, b := range map[string][]byte{"truncated": cases["truncated"], "symbols": cases["symbols"]} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, b, 0o644)
+		if err := runDecode([]string{"-out", filepath.Join(dir, "out"), p}); err == nil {
+			t.Errorf("%s: decode succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Fountain codes: LT codes with the robust soliton degree distribution,
+// decoded by peeling, and a Raptor-style variant that first extends the
+// k source chunks with a sparse XOR precode. LT symbols reuse Symbol with
+// the neighbor set as a GF(2) bit vector in Bits, so they travel through
+// the same gossip machinery as binary RLNC.
+
+// ErrNotPeeled is returned when peeling stalls before every source chunk
+// is recovered.
+var ErrNotPeeled = errors.New("peeling decoder: stalled before recovering all source chunks")
+
+// Robust soliton parameters (Luby): c scales the spike and delta bounds
+// the decoding failure probability.
+const (
+	solitonC     = 0.1
+	solitonDelta = 0.5
+)
+
+// robustSoliton returns the cumulative robust soliton distribution over
+// degrees 1..n; cdf[d-1] is P(degree <= d).
+func robustSoliton(n int) []float64 {
+	r := solitonC * math.Log(float64(n)/solitonDelta) * math.Sqrt(float64(n))
+	spike := int(math.Round(float64(n) / r))
+	p := make([]float64, n)
+	sum := 0.0
+	for d := 1; d <= n; d++ {
+		// Ideal soliton rho(d)
+		rho := 1 / float64(n)
+		if d > 1 {
+			rho = 1 / float64(d*(d-1))
+		}
+		// Robust extra tau(d)
+		tau := 0.0
+		switch {
+		case d < spike:
+			tau = r / float64(d*n)
+		case d == spike:
+			tau = r * math.Log(r/solitonDelta) / float64(n)
+		}
+		p[d-1] = rho + max(tau, 0)
+		sum += p[d-1]
+	}
+	acc := 0.0
+	for i := range p {
+		acc += p[i] / sum
+		p[i] = acc
+	}
+	return p
+}
+
+// raptorPrecode returns the parity checks of the Raptor-style precode for
+// k source chunks: check j lists the source chunks XORed into parity
+// chunk k+j. Every source chunk joins three checks. The structure depends
+// only on k, so encoder and decoder derive it independently.
+func raptorPrecode(k int) [][]int {
+	m := max(2, k/8)
+	rng := rand.New(rand.NewSource(int64(k)))
+	checks := make([][]int, m)
+	for i := 0; i < k; i++ {
+		for _, j := range rng.Perm(m)[:min(3, m)] {
+			checks[j] = append(checks[j], i)
+		}
+	}
+	return checks
+}
+
+// LTEncoder emits LT symbols over the k source chunks, or over the source
+// plus precode parity chunks when built with precode.
+type LTEncoder struct {
+	inter [][]byte // intermediate chunks: source, then parity
+	cdf   []float64
+	rng   *rand.Rand
+}
+
+func NewLTEncoder(src []Symbol, precode bool, rng *rand.Rand) *LTEncoder {
+	inter := make([][]byte, len(src))
+	for i, s := range src {
+		inter[i] = s.Data
+	}
+	if precode {
+		for _, check := range raptorPrecode(len(src)) {
+			parity := make([]byte, len(inter[0]))
+			for _, i := range check {
+				xorBytes(parity, inter[i])
+			}
+			inter = append(inter, parity)
+		}
+	}
+	return &LTEncoder{inter: inter, cdf: robustSoliton(len(inter)), rng: rng}
+}
+
+// Next returns a fresh LT symbol: a robust-soliton degree d, then the XOR
+// of d distinct intermediate chunks chosen uniformly.
+func (e *LTEncoder) Next() Symbol {
+	n := len(e.inter)
+	d := sort.SearchFloat64s(e.cdf, e.rng.Float64()) + 1
+	d = min(d, n)
+	vec := make([]uint64, packedWords(n))
+	data := make([]byte, len(e.inter[0]))
+	for _, i := range e.rng.Perm(n)[:d] {
+		vec[i/64] |= 1 << (i % 64)
+		xorBytes(data, e.inter[i])
+	}
+	return Symbol{Bits: vec, Data: data}
+}
+
+// bitsKey turns a packed bit vector into a map key.
+func bitsKey(v []uint64) string {
+	b := make([]byte, 0, 8*len(v))
+	for _, w := range v {
+		b = binary.LittleEndian.AppendUint64(b, w)
+	}
+	return string(b)
+}
+
+func xorBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// PeelingDecoder is the belief-propagation decoder for LT and Raptor
+// symbols. Each received symbol becomes an equation over the chunks it
+// still has unknown; whenever an equation is down to one chunk, that
+// chunk is recovered and XORed out of every other equation containing
+// it. Precode checks enter as equations with an all-zero right-hand side.
+type PeelingDecoder struct {
+	k       int      // source chunks; decoding is complete once all are known
+	chunks  [][]byte // recovered intermediate chunks, nil if unknown
+	known   int      // recovered source chunks
+	waiting [][]*ltEquation
+}
+
+type ltEquation struct {
+	unknown []int // intermediate chunks not yet recovered
+	data    []byte
+}
+
+// NewPeelingDecoder returns a decoder for k source chunks of size bytes,
+// with the Raptor precode checks preloaded when precode is set.
+func NewPeelingDecoder(k, size int, precode bool) *PeelingDecoder {
+	n := k
+	var checks [][]int
+	if precode {
+		checks = raptorPrecode(k)
+		n += len(checks)
+	}
+	d := &PeelingDecoder{k: k, chunks: make([][]byte, n), waiting: make([][]*ltEquation, n)}
+	for j, check := range checks {
+		d.insert(append(append([]int(nil), check...), k+j), make([]byte, size))
+	}
+	return d
+}
+
+// Add inserts an LT symbol and reports whether it carried anything new:
+// false means every chunk it covers was already recovered.
+func (d *PeelingDecoder) Add(sym *Symbol) bool {
+	var unknown []int
+	data := append([]byte(nil), sym.Data...)
+	for i := range d.chunks {
+		if bitAt(sym.Bits, i) == 0 {
+			continue
+		}
+		if d.chunks[i] != nil {
+			xorBytes(data, d.chunks[i])
+		} else {
+			unknown = append(unknown, i)
+		}
+	}
+	if len(unknown) == 0 {
+		return false
+	}
+	d.insert(unknown, data)
+	return true
+}
+
+// insert registers an equation and peels as far as it allows.
+func (d *PeelingDecoder) insert(unknown []int, data []byte) {
+	eq := &ltEquation{unknown: unknown, data: data}
+	for _, i := range unknown {
+		d.waiting[i] = append(d.waiting[i], eq)
+	}
+	ripple := []*ltEquation{eq}
+	for len(ripple) > 0 {
+		eq := ripple[len(ripple)-1]
+		ripple = ripple[:len(ripple)-1]
+		if len(eq.unknown) != 1 {
+			continue
+		}
+		c := eq.unknown[0]
+		if d.chunks[c] != nil {
+			continue
+		}
+		d.chunks[c] = eq.data
+		if c < d.k {
+			d.known++
+		}
+		// Substitute the recovered chunk everywhere it is still unknown
+		for _, other := range d.waiting[c] {
+			if other == eq {
+				continue
+			}
+			xorBytes(other.data, eq.data)
+			for j, u := range other.unknown {
+				if u == c {
+					other.unknown[j] = other.unknown[len(other.unknown)-1]
+					other.unknown = other.unknown[:len(other.unknown)-1]
+					break
+				}
+			}
+			if len(other.unknown) == 1 {
+				ripple = append(ripple, other)
+			}
+		}
+		d.waiting[c] = nil
+	}
+}
+
+// Recovered returns the number of source chunks recovered so far.
+func (d *PeelingDecoder) Recovered() int {
+	return d.known
+}
+
+func (d *PeelingDecoder) Complete() bool {
+	return d.known == d.k
+}
+
+// Data concatenates the k source chunks, or returns ErrNotPeeled if any
+// is still missing.
+func (d *PeelingDecoder) Data() ([]byte, error) {
+	if !d.Complete() {
+		return nil, ErrNotPeeled
+	}
+	out := make([]byte, 0, d.k*len(d.chunks[0]))
+	for _, c := range d.chunks[:d.k] {
+		out = append(out, c...)
+	}
+	return out, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// ltSymbol is the LT symbol over the chunks idx of src.
+func ltSymbol(src []Symbol, idx ...int) *Symbol {
+	sym := &Symbol{Bits: make([]uint64, packedWords(len(src))), Data: make([]byte, len(src[0].Data))}
+	for _, i := range idx {
+		sym.Bits[i/64] |= 1 << (i % 64)
+		xorBytes(sym.Data, src[i].Data)
+	}
+	return sym
+}
+
+// checkPeeled fails if any chunk d has recovered differs from the source.
+func checkPeeled(t *testing.T, name string, d *PeelingDecoder, src []Symbol) {
+	t.Helper()
+	for i, c := range d.chunks[:d.k] {
+		if c != nil && !bytes.Equal(c, src[i].Data) {
+			t.Fatalf("%s: chunk %d recovered wrong", name, i)
+		}
+	}
+}
+
+func TestFountainRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, k := range []int{1, 16, 100} {
+		c, err := NewCoding(8, 0, k, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, precode := range []bool{false, true} {
+			name := map[bool]string{false: "LT", true: "Raptor"}[precode]
+			src, syms := encodeFile(c, rng)
+			enc := NewLTEncoder(syms, precode, rng)
+			dec := NewPeelingDecoder(k, c.Size, precode)
+			n := 0
+			for ; !dec.Complete() && n < 20*k; n++ {
+				sym := enc.Next()
+				dec.Add(&sym)
+			}
+			got, err := dec.Data()
+			if err != nil {
+				t.Fatalf("%s k=%d: %v after %d symbols", name, k, err, n)
+			}
+			if !bytes.Equal(got, src) {
+				t.Fatalf("%s k=%d: decoded data differs from the source", name, k)
+			}
+			// Everything is known now, so nothing more is new
+			if sym := enc.Next(); dec.Add(&sym) {
+				t.Errorf("%s k=%d: symbol after completion counted as new", name, k)
+			}
+		}
+	}
+}
+
+// TestFountainStall feeds symbols that leave no degree-1 equation: the
+// decoder must report ErrNotPeeled and keep only correct chunks.
+func TestFountainStall(t *testing.T) {
+	c, _ := NewCoding(8, 0, 5, 32)
+	_, src := encodeFile(c, rand.New(rand.NewSource(2)))
+	for _, tc := range []struct {
+		name      string
+		syms      [][]int
+		recovered int
+	}{
+		{"nothing", nil, 0},
+		{"cycle", [][]int{{0, 1}, {1, 2}, {0, 2}, {3, 4}}, 0},
+		// Chunk 0 peels and turns 0^1^2 into 1^2; that, the cycle 1^3,
+		// 3^4, 1^4 and 1^3^4 leave nothing of degree one
+		{"after progress", [][]int{{0}, {0, 1, 2}, {1, 3}, {3, 4}, {1, 4}, {0, 3, 4, 1}}, 1},
+		// Plenty of symbols, all of even degree, never peel
+		{"even degrees", [][]int{{0, 1}, {2, 3}, {1, 2}, {3, 4}, {0, 4}, {1, 3}, {0, 2}, {2, 4}}, 0},
+	} {
+		dec := NewPeelingDecoder(5, c.Size, false)
+		for _, idx := range tc.syms {
+			dec.Add(ltSymbol(src, idx...))
+		}
+		if dec.Complete() || dec.Recovered() != tc.recovered {
+			t.Errorf("%s: complete %v with %d chunks, want %d", tc.name, dec.Complete(), dec.Recovered(), tc.recovered)
+		}
+		if out, err := dec.Data(); !errors.Is(err, ErrNotPeeled) || out != nil {
+			t.Errorf("%s: Data returned %d B, %v; want ErrNotPeeled", tc.name, len(out), err)
+		}
+		checkPeeled(t, tc.name, dec, src)
+	}
+
+	// Too few LT symbols stall too, however lucky the degrees
+	rng := rand.New(rand.NewSource(3))
+	c, _ = NewCoding(8, 0, 64, 32)
+	_, src = encodeFile(c, rng)
+	for _, precode := range []bool{false, true} {
+		enc := NewLTEncoder(src, precode, rng)
+		dec := NewPeelingDecoder(c.K, c.Size, precode)
+		for i := 0; i < c.K/2; i++ {
+			sym := enc.Next()
+			dec.Add(&sym)
+		}
+		if _, err := dec.Data(); !errors.Is(err, ErrNotPeeled) {
+			t.Errorf("precode %v: %d symbols for k=%d gave %v, want ErrNotPeeled", precode, c.K/2, c.K, err)
+		}
+		checkPeeled(t, "short", dec, src)
+	}
+}
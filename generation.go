@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Generations: an input larger than k*size bytes is cut into
+// consecutive generations of k symbols, each coded and decoded on its own.
+// Mixing only happens within a generation, so decoding cost stays O(k^2)
+// per symbol however large the input, and Symbol.Gen tells a receiver
+// which decoder a symbol belongs to.
+
+// readInput reads the whole input named by path; "-" is stdin.
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// generationCount is the number of generations needed for n bytes; an
+// empty input still takes one (all padding) generation.
+func (c *Coding) generationCount(n int) int {
+	gen := c.K * c.Size
+	return max(1, (n+gen-1)/gen)
+}
+
+// splitGenerations cuts data into generations of k source symbols,
+// zero-padding the tail of the last one. gens[g][i] is symbol i of
+// generation g, with Gen set.
+func (c *Coding) splitGenerations(data []byte) [][]Symbol {
+	n := c.generationCount(len(data))
+	padded := make([]byte, n*c.K*c.Size)
+	copy(padded, data)
+	gens := make([][]Symbol, n)
+	for g := range gens {
+		gens[g] = make([]Symbol, c.K)
+		for i := range gens[g] {
+			off := (g*c.K + i) * c.Size
+			gens[g][i] = Symbol{Gen: g, Data: padded[off : off+c.Size]}
+		}
+	}
+	return gens
+}
+
+// GenerationDecoder runs one RankDecoder per generation and routes each
+// symbol by its Gen. Rank, Ops and Data cover all generations together,
+// so it stands in for a single decoder wherever one is expected. A
+// generation's decoder is only created by its first symbol, so the
+// generation count alone costs no decoder state.
+type GenerationDecoder struct {
+	gens   []RankDecoder // nil until the generation's first symbol
+	newDec func() RankDecoder
+	done   int // generations at full rank
+}
+
+func NewGenerationDecoder(n int, newDec func() RankDecoder) *GenerationDecoder {
+	return &GenerationDecoder{gens: make([]RankDecoder, n), newDec: newDec}
+}
+
+// Add inserts a symbol into its generation's decoder and reports whether
+// it was innovative there. A symbol of a generation outside the decoder's
+// range adds nothing.
+func (d *GenerationDecoder) Add(sym *Symbol) bool {
+	if sym.Gen < 0 || sym.Gen >= len(d.gens) {
+		return false
+	}
+	dec := d.gens[sym.Gen]
+	if dec == nil {
+		dec = d.newDec()
+		d.gens[sym.Gen] = dec
+	}
+	if !dec.Add(sym) {
+		return false
+	}
+	if dec.Complete() {
+		d.done++
+	}
+	return true
+}
+
+// Rank returns the rank summed over all generations.
+func (d *GenerationDecoder) Rank() int {
+	r := 0
+	for _, dec := range d.gens {
+		if dec != nil {
+			r += dec.Rank()
+		}
+	}
+	return r
+}
+
+// GenRank returns the rank of generation g.
+func (d *GenerationDecoder) GenRank(g int) int {
+	if d.gens[g] == nil {
+		return 0
+	}
+	return d.gens[g].Rank()
+}
+
+func (d *GenerationDecoder) Generations() int {
+	return len(d.gens)
+}
+
+// Done returns the number of generations at full rank.
+func (d *GenerationDecoder) Done() int {
+	return d.done
+}
+
+func (d *GenerationDecoder) Complete() bool {
+	return d.done == len(d.gens)
+}
+
+func (d *GenerationDecoder) Ops() int {
+	ops := 0
+	for _, dec := range d.gens {
+		if dec != nil {
+			ops += dec.Ops()
+		}
+	}
+	return ops
+}
+
+// Data returns every generation's source symbols concatenated, padding
+// included, or the first generation's error.
+func (d *GenerationDecoder) Data() ([]byte, error) {
+	var out []byte
+	for g, dec := range d.gens {
+		if dec == nil {
+			// Never got a symbol; an empty decoder reports the rank
+			dec = d.newDec()
+		}
+		b, err := dec.Data()
+		if err != nil {
+			return nil, fmt.Errorf("generation %d: %w", g, err)
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
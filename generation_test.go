@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestGenerationRoundTrip splits inputs around the generation size into
+// generations, codes each one, decodes them with symbols of all
+// generations interleaved, and strips the padding again.
+func TestGenerationRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const k, size = 4, 16
+	for _, bits := range []int{1, 8, 16} {
+		c, err := NewCoding(bits, 0, k, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int{0, 1, k * size, 3 * k * size, 3*k*size + 1} {
+			data := make([]byte, n)
+			rng.Read(data)
+			gens := c.splitGenerations(data)
+			want := max(1, (n+k*size-1)/(k*size))
+			if len(gens) != want || c.generationCount(n) != want {
+				t.Fatalf("GF(2^%d) %d B: %d generations, count says %d, want %d", bits, n, len(gens), c.generationCount(n), want)
+			}
+			var joined []byte
+			for g, syms := range gens {
+				if len(syms) != k {
+					t.Fatalf("GF(2^%d) %d B: generation %d has %d symbols", bits, n, g, len(syms))
+				}
+				for _, s := range syms {
+					if s.Gen != g || len(s.Data) != size {
+						t.Fatalf("GF(2^%d) %d B: symbol of %d B in generation %d, want %d B in %d", bits, n, len(s.Data), s.Gen, size, g)
+					}
+					joined = append(joined, s.Data...)
+				}
+			}
+			if !bytes.Equal(joined[:n], data) || !bytes.Equal(joined[n:], make([]byte, len(joined)-n)) {
+				t.Fatalf("GF(2^%d) %d B: split does not hold the input followed by zeros", bits, n)
+			}
+
+			dec := NewGenerationDecoder(len(gens), func() RankDecoder { return NewProgressiveDecoder(c.GF, k) })
+			for tries := 0; !dec.Complete() && tries < 100*k*len(gens); tries++ {
+				g := rng.Intn(len(gens))
+				sym := mixSymbol(gens[g], c, rng)
+				sym.Gen = g
+				dec.Add(&sym)
+			}
+			out, err := dec.Data()
+			if err != nil {
+				t.Fatalf("GF(2^%d) %d B: %v", bits, n, err)
+			}
+			if !bytes.Equal(out, joined) || !bytes.Equal(out[:n], data) {
+				t.Fatalf("GF(2^%d) %d B: decoded data differs from the input", bits, n)
+			}
+		}
+	}
+}
+
+// TestGenerationMissing checks that one generation short of rank fails
+// the whole decode, naming that generation.
+func TestGenerationMissing(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	c, _ := NewCoding(8, 0, 4, 16)
+	data := make([]byte, 3*4*16)
+	rng.Read(data)
+	gens := c.splitGenerations(data)
+	dec := NewGenerationDecoder(len(gens), func() RankDecoder { return NewProgressiveDecoder(c.GF, c.K) })
+	for g := 0; g < len(gens); g += 2 {
+		for i := 0; i < 2*c.K; i++ {
+			sym := mixSymbol(gens[g], c, rng)
+			sym.Gen = g
+			dec.Add(&sym)
+		}
+	}
+	if dec.Complete() || dec.GenRank(0) != c.K || dec.GenRank(1) != 0 {
+		t.Fatalf("complete %v, generation ranks %d and %d", dec.Complete(), dec.GenRank(0), dec.GenRank(1))
+	}
+	_, err := dec.Data()
+	if !errors.Is(err, ErrRankDeficient) || !strings.HasPrefix(err.Error(), "generation 1:") {
+		t.Errorf("error %v, want generation 1 rank deficient", err)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Default primitive polynomials for each supported field width.
+// 0x11d is the polynomial used by most RLNC and RS libraries.
+var defaultPoly = map[int]int{
+	1:  0x3,
+	8:  0x11d,
+	16: 0x1100b,
+}
+
+// GF represents the Galois Field GF(2^bits) generated by a primitive
+// polynomial. Addition is XOR; multiplication, division, inverse and
+// power go through log/antilog tables.
+type GF struct {
+	bits int
+	size int
+	poly int
+	gen  int   // generator of the multiplicative group
+	exp  []int // exp[i] = gen^i, doubled so log sums need no modulo
+	log  []int // log[gen^i] = i, log[0] unused
+}
+
+// NewGF builds the log/antilog tables for GF(2^bits) modulo poly.
+// A poly of 0 selects the default polynomial for the width. An error is
+// returned if poly is not of degree bits or is not irreducible.
+func NewGF(bits, poly int) (*GF, error) {
+	if poly == 0 {
+		poly = defaultPoly[bits]
+	}
+	if bits <= 0 || poly>>bits != 1 {
+		return nil, fmt.Errorf("polynomial %#x is not of degree %d", poly, bits)
+	}
+	size := 1 << bits
+	gf := &GF{
+		bits: bits,
+		size: size,
+		poly: poly,
+		exp:  make([]int, 2*(size-1)),
+		log:  make([]int, size),
+	}
+
+	// Rule out reducible moduli first: the generator search below would
+	// try every element, O(size^2) in all, before giving up on them.
+	if !gf.irreducible() {
+		return nil, fmt.Errorf("polynomial %#x is not irreducible over GF(2)", poly)
+	}
+	// Search for a generator rather than assuming x (0x02) is one. This
+	// lets non-primitive but irreducible moduli like 0x11b work.
+	for g := 1; g < size; g++ {
+		if gf.fill(g) {
+			gf.gen = g
+			return gf, nil
+		}
+	}
+	return nil, fmt.Errorf("polynomial %#x is not irreducible over GF(2)", poly)
+}
+
+// fill populates exp/log from powers of g and reports whether g
+// generates the whole multiplicative group.
+func (gf *GF) fill(g int) bool {
+	x := 1
+	for i := 0; i < gf.size-1; i++ {
+		if i > 0 && x == 1 {
+			return false
+		}
+		gf.exp[i] = x
+		gf.exp[i+gf.size-1] = x
+		gf.log[x] = i
+		x = gf.slowMul(x, g)
+	}
+	return x == 1
+}
+
+// irreducible reports whether poly is irreducible over GF(2), by Rabin's
+// test: x^(2^bits) = x modulo poly, and x^(2^d) - x is coprime to poly for
+// every proper divisor d of bits.
+func (gf *GF) irreducible() bool {
+	if gf.bits == 1 {
+		return true
+	}
+	// x^(2^m) modulo poly, by repeated squaring of x
+	xPow := func(m int) int {
+		x := 2
+		for i := 0; i < m; i++ {
+			x = gf.slowMul(x, x)
+		}
+		return x
+	}
+	if xPow(gf.bits) != 2 {
+		return false
+	}
+	for d := 1; d < gf.bits; d++ {
+		if gf.bits%d == 0 && polyGCD(xPow(d)^2, gf.poly) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// polyGCD is the greatest common divisor of two polynomials over GF(2),
+// bit i holding the coefficient of x^i.
+func polyGCD(a, b int) int {
+	for b != 0 {
+		for bits.Len(uint(a)) >= bits.Len(uint(b)) {
+			a ^= b << (bits.Len(uint(a)) - bits.Len(uint(b)))
+		}
+		a, b = b, a
+	}
+	return a
+}
+
+// slowMul multiplies by shift-and-add with reduction modulo poly. It is
+// only used while building the tables.
+func (gf *GF) slowMul(a, b int) int {
+	r := 0
+	for b > 0 {
+		if b&1 != 0 {
+			r ^= a
+		}
+		b >>= 1
+		a <<= 1
+		if a&gf.size != 0 {
+			a ^= gf.poly
+		}
+	}
+	return r
+}
+
+// WordSize is the number of data bytes one field element covers: 1 for
+// GF(2) and GF(2^8), 2 for GF(2^16). Payloads are processed as big-endian words.
+func (gf *GF) WordSize() int {
+	return (gf.bits + 7) / 8
+}
+
+// checkWords panics on a payload that ends in part of a word, which
+// MulAdd and Scale would otherwise leave unchanged.
+func (gf *GF) checkWords(b []byte) {
+	if len(b)%gf.WordSize() != 0 {
+		panic(fmt.Sprintf("gf: %d B payload is not whole %d B words", len(b), gf.WordSize()))
+	}
+}
+
+func (gf *GF) Add(a, b uint16) uint16 {
+	return a ^ b
+}
+
+func (gf *GF) Sub(a, b uint16) uint16 {
+	return a ^ b
+}
+
+func (gf *GF) Mul(a, b uint16) uint16 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return uint16(gf.exp[gf.log[a]+gf.log[b]])
+}
+
+// Div returns a/b. It panics if b is zero.
+func (gf *GF) Div(a, b uint16) uint16 {
+	if b == 0 {
+		panic("gf: division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return uint16(gf.exp[gf.log[a]+gf.size-1-gf.log[b]])
+}
+
+// Inv returns the multiplicative inverse of a. It panics if a is zero.
+func (gf *GF) Inv(a uint16) uint16 {
+	return gf.Div(1, a)
+}
+
+// Pow returns a^n. Negative exponents are allowed for non-zero a.
+func (gf *GF) Pow(a uint16, n int) uint16 {
+	if a == 0 {
+		if n == 0 {
+			return 1
+		}
+		return 0
+	}
+	e := (gf.log[a] * n) % (gf.size - 1)
+	if e < 0 {
+		e += gf.size - 1
+	}
+	return uint16(gf.exp[e])
+}
+
+// MulAdd computes dst += c*src over whole payloads, word by word. It
+// panics if len(src) is not a multiple of WordSize.
+func (gf *GF) MulAdd(dst, src []byte, c uint16) {
+	gf.checkWords(src)
+	if c == 0 {
+		return
+	}
+	if c == 1 {
+		for i := range src {
+			dst[i] ^= src[i]
+		}
+		return
+	}
+	lc := gf.log[c]
+	switch gf.WordSize() {
+	case 1:
+		for i, b := range src {
+			if b != 0 {
+				dst[i] ^= byte(gf.exp[gf.log[b]+lc])
+			}
+		}
+	case 2:
+		for i := 0; i+1 < len(src); i += 2 {
+			w := int(src[i])<<8 | int(src[i+1])
+			if w != 0 {
+				p := gf.exp[gf.log[w]+lc]
+				dst[i] ^= byte(p >> 8)
+				dst[i+1] ^= byte(p)
+			}
+		}
+	}
+}
+
+// Scale computes buf *= c in place, word by word. It panics if len(buf)
+// is not a multiple of WordSize.
+func (gf *GF) Scale(buf []byte, c uint16) {
+	gf.checkWords(buf)
+	if c == 1 {
+		return
+	}
+	if c == 0 {
+		for i := range buf {
+			buf[i] = 0
+		}
+		return
+	}
+	lc := gf.log[c]
+	switch gf.WordSize() {
+	case 1:
+		for i, b := range buf {
+			if b != 0 {
+				buf[i] = byte(gf.exp[gf.log[b]+lc])
+			}
+		}
+	case 2:
+		for i := 0; i+1 < len(buf); i += 2 {
+			w := int(buf[i])<<8 | int(buf[i+1])
+			if w != 0 {
+				p := gf.exp[gf.log[w]+lc]
+				buf[i] = byte(p >> 8)
+				buf[i+1] = byte(p)
+			}
+		}
+	}
+}
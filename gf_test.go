@@ -0,0 +1,180 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// gfFields are the fields the axiom tests run over; 0x11b is irreducible
+// but not primitive, so x does not generate it.
+var gfFields = []struct{ bits, poly int }{
+	{1, 0x3},
+	{8, 0x11d},
+	{8, 0x11b},
+	{16, 0x1100b},
+}
+
+// gfSamples returns every element of small fields and a random sample,
+// zero and one included, of GF(2^16).
+func gfSamples(gf *GF, rng *rand.Rand) []uint16 {
+	if gf.size <= 256 {
+		all := make([]uint16, gf.size)
+		for i := range all {
+			all[i] = uint16(i)
+		}
+		return all
+	}
+	s := []uint16{0, 1, 2, uint16(gf.size - 1)}
+	for len(s) < 200 {
+		s = append(s, uint16(rng.Intn(gf.size)))
+	}
+	return s
+}
+
+func TestGFAxioms(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, f := range gfFields {
+		gf, err := NewGF(f.bits, f.poly)
+		if err != nil {
+			t.Fatalf("GF(2^%d) mod %#x: %v", f.bits, f.poly, err)
+		}
+		xs := gfSamples(gf, rng)
+		for _, a := range xs {
+			if gf.Mul(a, 1) != a || gf.Mul(a, 0) != 0 || gf.Add(a, a) != 0 {
+				t.Fatalf("GF(2^%d) mod %#x: identities fail for %d", f.bits, f.poly, a)
+			}
+			for _, b := range xs {
+				ab := gf.Mul(a, b)
+				if ab != uint16(gf.slowMul(int(a), int(b))) || ab != gf.Mul(b, a) {
+					t.Fatalf("GF(2^%d) mod %#x: %d*%d = %d, slow multiply says %d", f.bits, f.poly, a, b, ab, gf.slowMul(int(a), int(b)))
+				}
+				c := xs[rng.Intn(len(xs))]
+				if gf.Mul(ab, c) != gf.Mul(a, gf.Mul(b, c)) {
+					t.Fatalf("GF(2^%d) mod %#x: (%d*%d)*%d not associative", f.bits, f.poly, a, b, c)
+				}
+				if gf.Mul(a, gf.Add(b, c)) != gf.Add(ab, gf.Mul(a, c)) {
+					t.Fatalf("GF(2^%d) mod %#x: %d*(%d+%d) not distributive", f.bits, f.poly, a, b, c)
+				}
+				if b != 0 && gf.slowMul(int(gf.Div(a, b)), int(b)) != int(a) {
+					t.Fatalf("GF(2^%d) mod %#x: (%d/%d)*%d != %d", f.bits, f.poly, a, b, b, a)
+				}
+			}
+		}
+	}
+}
+
+func TestGFInvPow(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, f := range gfFields {
+		gf, _ := NewGF(f.bits, f.poly)
+		for _, a := range gfSamples(gf, rng) {
+			if a != 0 && gf.slowMul(int(a), int(gf.Inv(a))) != 1 {
+				t.Fatalf("GF(2^%d) mod %#x: %d*Inv(%d) != 1", f.bits, f.poly, a, a)
+			}
+			want := 1
+			for n := 0; n <= 10; n++ {
+				if got := gf.Pow(a, n); int(got) != want {
+					t.Fatalf("GF(2^%d) mod %#x: %d^%d = %d, want %d", f.bits, f.poly, a, n, got, want)
+				}
+				if a != 0 && n > 0 && gf.Pow(a, -n) != gf.Inv(uint16(want)) {
+					t.Fatalf("GF(2^%d) mod %#x: %d^-%d is not the inverse of %d^%d", f.bits, f.poly, a, n, a, n)
+				}
+				want = gf.slowMul(want, int(a))
+			}
+		}
+	}
+}
+
+func TestGFRejectsReducible(t *testing.T) {
+	for _, f := range []struct{ bits, poly int }{
+		{8, 0x3},       // wrong degree
+		{8, 0x100},     // x^8
+		{8, 0x101},     // (x^4+1)^2
+		{16, 0x10001},  // (x+1)^16
+		{16, 0x1100a},  // divisible by x
+		{16, 0x10003},  // x^16+x+1, reducible
+		{16, 0x100000}, // degree 20
+	} {
+		if _, err := NewGF(f.bits, f.poly); err == nil {
+			t.Errorf("GF(2^%d) mod %#x accepted", f.bits, f.poly)
+		}
+	}
+	// There are 30 irreducible polynomials of degree 8 over GF(2)
+	n := 0
+	for poly := 0x100; poly < 0x200; poly++ {
+		if _, err := NewGF(8, poly); err == nil {
+			n++
+		}
+	}
+	if n != 30 {
+		t.Errorf("%d degree-8 moduli accepted, want the 30 irreducible ones", n)
+	}
+}
+
+// TestGFPayload checks MulAdd and Scale against word-by-word slow
+// multiplication, and that GF(2^16) refuses payloads ending in half a word.
+func TestGFPayload(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, f := range gfFields {
+		gf, _ := NewGF(f.bits, f.poly)
+		ws := gf.WordSize()
+		for _, n := range []int{0, 1, 3, 64, 1023} {
+			size := n * ws
+			src, dst := make([]byte, size), make([]byte, size)
+			rng.Read(src)
+			rng.Read(dst)
+			for _, c := range []uint16{0, 1, uint16(rng.Intn(gf.size)), uint16(gf.size - 1)} {
+				if gf.bits == 1 {
+					// GF(2) payloads are bytes of bits; only 0 and 1 scale them
+					c &= 1
+				}
+				want := append([]byte(nil), dst...)
+				scaled := append([]byte(nil), src...)
+				for i := 0; i < size; i += ws {
+					var w int
+					for j := 0; j < ws; j++ {
+						w = w<<8 | int(src[i+j])
+					}
+					p := w
+					if gf.bits > 1 {
+						p = gf.slowMul(w, int(c))
+					} else if c == 0 {
+						p = 0
+					}
+					for j := ws - 1; j >= 0; j-- {
+						want[i+j] ^= byte(p >> (8 * (ws - 1 - j)))
+						scaled[i+j] = byte(p >> (8 * (ws - 1 - j)))
+					}
+				}
+				got := append([]byte(nil), dst...)
+				gf.MulAdd(got, src, c)
+				if string(got) != string(want) {
+					t.Fatalf("GF(2^%d): MulAdd by %d over %d B is wrong", f.bits, c, size)
+				}
+				got = append([]byte(nil), src...)
+				gf.Scale(got, c)
+				if string(got) != string(scaled) {
+					t.Fatalf("GF(2^%d): Scale by %d over %d B is wrong", f.bits, c, size)
+				}
+			}
+		}
+	}
+
+	gf, _ := NewGF(16, 0)
+	for _, size := range []int{1, 3, 1025} {
+		buf := make([]byte, size)
+		for name, f := range map[string]func(){
+			"MulAdd": func() { gf.MulAdd(buf, buf, 3) },
+			"Scale":  func() { gf.Scale(buf, 3) },
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("GF(2^16) %s over %d B did not panic", name, size)
+					}
+				}()
+				f()
+			}()
+		}
+	}
+}
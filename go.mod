@@ -4,12 +4,11 @@ go 1.21
 
 require (
 	github.com/gorilla/websocket v1.5.1
-	gonum.org/v1/gonum v0.14.0
+	github.com/klauspost/reedsolomon v1.12.4
 )
 
 require (
 	github.com/klauspost/cpuid/v2 v2.2.8 // indirect
-	github.com/klauspost/reedsolomon v1.12.4 // indirect
 	github.com/templexxx/cpufeat v0.0.0-20180724012125-cef66df7f161 // indirect
 	github.com/templexxx/xor v0.0.0-20191217153810-f85b25db303b // indirect
 	golang.org/x/net v0.17.0 // indirect
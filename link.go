@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Link is a one-way connection to a neighbor. A message spends
+// size/bandwidth on the wire (queued behind earlier messages on the same
+// link), then delay plus a jitter sample in flight.
+type Link struct {
+	to        *Peer
+	addr      string        // live peers: the neighbor's transport address, in place of to
+	ch        Channel       // loss model, one instance per link
+	delay     time.Duration // propagation delay
+	jitter    Jitter        // extra random delay, nil for none
+	bandwidth float64       // bits per second, 0 for unlimited
+	busyUntil time.Duration // virtual time the link finishes its current transmission
+}
+
+// transit returns how long after now a message of size bytes sent over l
+// arrives, and books the link for its serialization time.
+func (l *Link) transit(now time.Duration, size int, rng *rand.Rand) time.Duration {
+	start := max(now, l.busyUntil)
+	l.busyUntil = start + serialization(size, l.bandwidth)
+	d := l.busyUntil - now + l.delay
+	if l.jitter != nil {
+		d += l.jitter.Sample(rng)
+	}
+	return d
+}
+
+// serialization is the time to put size bytes on a link of the given
+// bandwidth in bits per second.
+func serialization(size int, bandwidth float64) time.Duration {
+	if bandwidth <= 0 {
+		return 0
+	}
+	return time.Duration(float64(size*8) / bandwidth * float64(time.Second))
+}
+
+// wireSize is the number of bytes msg occupies on a link: the payload plus
+// the coefficient header.
+func (m Msg) wireSize(c *Coding) int {
+	if m.DataOnly != nil {
+		return len(m.DataOnly)
+	}
+	if m.Packet != nil {
+		return len(m.Packet)
+	}
+	return m.headerSize(c) + len(m.Sym.Data)
+}
+
+// headerSize is the coefficient header of msg: a seed for seeded symbols,
+// otherwise k field elements, or a packed bit vector in GF(2) and LT mode.
+// Plain and RS messages carry only the shard index, counted as free. A
+// serialized message counts everything in its packet but the payload.
+func (m Msg) headerSize(c *Coding) int {
+	switch {
+	case m.DataOnly != nil:
+		return 0
+	case m.Packet != nil:
+		return len(m.Packet) - len(m.Sym.Data)
+	case m.Sym.Seeded:
+		return seedHeaderBytes
+	case m.Sym.Bits != nil:
+		// Bits are sent packed to whole bytes, as in the wire format
+		return (c.K + 7) / 8
+	}
+	return c.coeffBytes()
+}
+
+// Jitter draws the random part of a link's delay. Samples are never
+// negative, so jitter only adds to the propagation delay.
+type Jitter interface {
+	Sample(rng *rand.Rand) time.Duration
+}
+
+// UniformJitter is uniform on [0, Max).
+type UniformJitter struct{ Max time.Duration }
+
+func (j UniformJitter) Sample(rng *rand.Rand) time.Duration {
+	if j.Max <= 0 {
+		return 0
+	}
+	return time.Duration(rng.Int63n(int64(j.Max)))
+}
+
+// NormalJitter is the absolute value of a zero-mean normal with standard
+// deviation StdDev.
+type NormalJitter struct{ StdDev time.Duration }
+
+func (j NormalJitter) Sample(rng *rand.Rand) time.Duration {
+	return time.Duration(math.Abs(rng.NormFloat64()) * float64(j.StdDev))
+}
+
+// ExpJitter is exponential with the given Mean, a heavy-ish tail typical
+// of queueing delay.
+type ExpJitter struct{ Mean time.Duration }
+
+func (j ExpJitter) Sample(rng *rand.Rand) time.Duration {
+	return time.Duration(rng.ExpFloat64() * float64(j.Mean))
+}
+
+// parseJitter parses a jitter spec: "" or "none", "uniform:MAX",
+// "normal:STDDEV" or "exp:MEAN", where the parameter is a duration.
+func parseJitter(spec string) (Jitter, error) {
+	if spec == "" || spec == "none" {
+		return nil, nil
+	}
+	kind, arg, _ := strings.Cut(spec, ":")
+	d, err := parseDelay(arg)
+	if err != nil {
+		return nil, fmt.Errorf("jitter %q: want %s:DURATION (%w)", spec, kind, err)
+	}
+	switch kind {
+	case "uniform":
+		return UniformJitter{Max: d}, nil
+	case "normal":
+		return NormalJitter{StdDev: d}, nil
+	case "exp":
+		return ExpJitter{Mean: d}, nil
+	}
+	return nil, fmt.Errorf("unknown jitter distribution %q (want uniform, normal or exp)", kind)
+}
+
+// parseBandwidth parses a rate in bits per second with an optional
+// bps/kbps/Mbps/Gbps suffix, e.g. "10Mbps". "" and "0" mean unlimited;
+// the rate must be finite.
+func parseBandwidth(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	num, mult := s, 1.0
+	for _, u := range []struct {
+		suffix string
+		mult   float64
+	}{{"Gbps", 1e9}, {"Mbps", 1e6}, {"kbps", 1e3}, {"bps", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			num, mult = strings.TrimSuffix(s, u.suffix), u.mult
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	bps := v * mult
+	// Also rejects NaN, and Inf spelled out or reached by overflow
+	if err != nil || !(bps >= 0 && bps <= math.MaxFloat64) {
+		return 0, fmt.Errorf("bad bandwidth %q (want e.g. 10Mbps)", s)
+	}
+	return bps, nil
+}
+
+// formatBandwidth is the display form of a rate in bits per second.
+func formatBandwidth(bps float64) string {
+	switch {
+	case bps <= 0:
+		return "unlimited"
+	case bps >= 1e9:
+		return strconv.FormatFloat(bps/1e9, 'g', -1, 64) + "Gbps"
+	case bps >= 1e6:
+		return strconv.FormatFloat(bps/1e6, 'g', -1, 64) + "Mbps"
+	case bps >= 1e3:
+		return strconv.FormatFloat(bps/1e3, 'g', -1, 64) + "kbps"
+	}
+	return strconv.FormatFloat(bps, 'g', -1, 64) + "bps"
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	for s, want := range map[string]float64{
+		"":        0,
+		"0":       0,
+		"1500":    1500,
+		"64kbps":  64e3,
+		"10Mbps":  10e6,
+		"2.5Gbps": 2.5e9,
+		"100bps":  100,
+	} {
+		if got, err := parseBandwidth(s); err != nil || got != want {
+			t.Errorf("%q: got %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"fast", "10MB", "-1Mbps", "NaN", "NaNMbps", "Inf", "+Infbps", "-Inf", "1e308Gbps"} {
+		if got, err := parseBandwidth(s); err == nil {
+			t.Errorf("%q: accepted as %v", s, got)
+		}
+	}
+}
+
+func TestParseJitter(t *testing.T) {
+	for s, want := range map[string]Jitter{
+		"":              nil,
+		"none":          nil,
+		"uniform:2ms":   UniformJitter{Max: 2 * time.Millisecond},
+		"normal:500us":  NormalJitter{StdDev: 500 * time.Microsecond},
+		"exp:3":         ExpJitter{Mean: 3 * time.Millisecond},
+		"uniform:0.5":   UniformJitter{Max: 500 * time.Microsecond},
+		"exp:1h30m0.5s": ExpJitter{Mean: 90*time.Minute + 500*time.Millisecond},
+	} {
+		if got, err := parseJitter(s); err != nil || got != want {
+			t.Errorf("%q: got %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"uniform", "uniform:", "pareto:1ms", "normal:-1ms", "exp:-2", "uniform:NaN", "exp:Inf", "normal:1e300"} {
+		if got, err := parseJitter(s); err == nil {
+			t.Errorf("%q: accepted as %v", s, got)
+		}
+	}
+}
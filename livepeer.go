@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Live peers run the RLNC gossip of the simulator in real time over a
+// Transport, one generation of random data, so a mesh of separate
+// processes on loopback can be compared against a simulated run. Every
+// peer regenerates the source data from the shared -seed to verify its
+// decode; only the source codes from it.
+
+// liveConfig is what every peer of a live mesh has to agree on, plus the
+// source's sending schedule.
+type liveConfig struct {
+	c        *Coding
+	src      []Symbol // source chunks
+	data     []byte   // src concatenated, to verify decodes against
+	recode   bool
+	seeded   bool          // the source sends coefficient seeds
+	send     int           // symbols the source sends
+	interval time.Duration // pause between source symbols
+}
+
+// LivePeer is a Peer that talks over a Transport in wall-clock time. The
+// gossip itself (decoding, forwarding, recoding) is Peer's; LivePeer only
+// feeds it packets and paces the source.
+type LivePeer struct {
+	*Peer
+	cfg *liveConfig
+}
+
+// NewLivePeer sends to each of links by its addr, dropping what the
+// link's channel drops.
+func NewLivePeer(id int, t Transport, links []*Link, cfg *liveConfig, rng *rand.Rand) *LivePeer {
+	p := NewPeer(id, nil, cfg.c, rng, schemeRLNC, cfg.recode)
+	p.t, p.wire, p.links = t, true, links
+	return &LivePeer{Peer: p, cfg: cfg}
+}
+
+// RunSource sends cfg.send coded symbols, cfg.interval apart.
+func (p *LivePeer) RunSource() error {
+	for i := 0; i < p.cfg.send; i++ {
+		var sym Symbol
+		if p.cfg.seeded {
+			sym = mixSeeded(p.cfg.src, p.cfg.c, p.rng)
+		} else {
+			sym = mixSymbol(p.cfg.src, p.cfg.c, p.rng)
+		}
+		p.forward(Msg{Sym: sym})
+		if p.err != nil {
+			return p.err
+		}
+		time.Sleep(p.cfg.interval)
+	}
+	return nil
+}
+
+// Run receives until the peer reaches full rank or deadline passes,
+// forwarding or recoding innovative symbols as it goes, then decodes.
+// Times in the result count from start, or from the first packet if
+// start is zero (a separate process does not know when the source began).
+func (p *LivePeer) Run(start, deadline time.Time) (decodeResult, error) {
+	p.start = start
+	for !p.complete() {
+		pkt, err := p.t.Recv(deadline)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+		if errors.Is(err, ErrOversized) {
+			p.recvCount++
+			p.corrupt++
+			continue
+		}
+		if err != nil {
+			return decodeResult{}, err
+		}
+		if p.start.IsZero() {
+			p.start = time.Now()
+		}
+		p.receive(Msg{Packet: pkt})
+		if p.err != nil {
+			return decodeResult{}, p.err
+		}
+	}
+	if !p.complete() {
+		return p.withMetrics(decodeResult{peer: p.id, err: fmt.Errorf("timed out at rank %d/%d", p.dec.Rank(), p.c.K)}), nil
+	}
+	return p.withMetrics(decodePeer(p.Peer, false, p.cfg.data)), nil
+}
+
+// summary is the one-line report of a finished peer.
+func (p *LivePeer) summary(res decodeResult) string {
+	var outcome string
+	switch {
+	case res.ok:
+		outcome = fmt.Sprintf("full rank after %v, decoded OK in %v", res.timeToRank.Round(time.Microsecond), res.duration)
+	case res.err != nil:
+		outcome = "decode failed: " + res.err.Error()
+	default:
+		outcome = "decoded data does not match source"
+	}
+	return fmt.Sprintf("peer %d (%s): %s; %d packets received (%d duplicate, %d dependent, %d corrupt, %d B), %d sent",
+		p.id, p.t.Addr(), outcome, p.recvCount, p.dupCount, p.depCount, p.corrupt, p.recvBytes, p.sent)
+}
+
+// runPeer implements "peer": one live peer bound to a UDP port, or with
+// -local a whole mesh of them in this process over a MemNetwork.
+func runPeer(args []string) error {
+	fs := flag.NewFlagSet("peer", flag.ExitOnError)
+	id := fs.Int("id", 0, "Peer number, for the report and the coefficient stream")
+	listen := fs.String("listen", "127.0.0.1:9000", "UDP address to bind")
+	neighborList := fs.String("neighbors", "", "Comma-separated UDP addresses this peer sends to")
+	source := fs.Bool("source", false, "Send coded symbols of the source data instead of receiving")
+	send := fs.Int("n", 0, "Source: coded symbols to send (default 3k, as in the simulator)")
+	interval := fs.Duration("interval", time.Millisecond, "Source: pause between symbols")
+	timeout := fs.Duration("timeout", 10*time.Second, "Receiver: give up if not at full rank by then")
+	seed := fs.Int64("seed", 1, "Seed of the source data; must be the same on every peer")
+	recode := fs.Bool("recode", false, "Recode at this peer instead of forwarding received symbols")
+	seedCoeff := fs.Bool("seedcoeff", false, "Source: send coefficient vectors as a 4-byte PRNG seed")
+	lossProb := fs.Float64("loss", 0, "Packet loss probability applied when sending, on top of the real network")
+	channelSpec := fs.String("channel", "", "Loss model applied when sending, as for the simulator")
+	genSize := fs.Int("k", defaultK, "Generation size: source symbols per generation")
+	symSize := fs.Int("symsize", defaultSize, "Symbol payload size in bytes")
+	fieldBits := fs.Int("field", 8, "Number of bits for Galois Field (1, 8 or 16)")
+	poly := fs.Int("poly", 0, "Primitive polynomial for the Galois Field (0 = default for -field)")
+	local := fs.Bool("local", false, "Run a whole mesh in this process over in-memory transports, peer 0 as source")
+	numPeers := fs.Int("peers", 4, "-local: number of peers")
+	topology := fs.String("topology", "random", "-local: topology, as for the simulator")
+	fanout := fs.Int("fanout", 2, "-local: out-neighbors per peer for the random and regular topologies")
+	edgeProb := fs.Float64("edgeprob", 0.5, "-local: edge probability for the er topology")
+	topoFile := fs.String("topofile", "", "-local: load the topology from a file instead")
+	fs.Parse(args)
+
+	c, err := NewCoding(*fieldBits, *poly, *genSize, *symSize)
+	if err != nil {
+		return err
+	}
+	if c.K > 0xffff || c.Size > 0xffff {
+		return errors.New("the wire format holds -k and -symsize up to 65535")
+	}
+	newChannel, err := parseChannel(*channelSpec, *lossProb)
+	if err != nil {
+		return err
+	}
+	if *send == 0 {
+		*send = 3 * c.K
+	}
+	data, src := encodeFile(c, rand.New(rand.NewSource(*seed)))
+	cfg := &liveConfig{c: c, src: src, data: data, recode: *recode, seeded: *seedCoeff, send: *send, interval: *interval}
+	// Each peer draws coefficients and losses from its own stream
+	peerRNG := func(id int) *rand.Rand { return rand.New(rand.NewSource(*seed + int64(id) + 1)) }
+
+	if *local {
+		var graph *Graph
+		if *topoFile != "" {
+			graph, err = loadTopology(*topoFile)
+		} else {
+			graph, err = buildTopology(*topology, *numPeers, *fanout, *edgeProb, rand.New(rand.NewSource(*seed)))
+		}
+		if err != nil {
+			return err
+		}
+		return runLocalMesh(graph, newChannel, cfg, *timeout, peerRNG)
+	}
+
+	var neighbors []string
+	if *neighborList != "" {
+		neighbors = strings.Split(*neighborList, ",")
+	}
+	if len(neighbors) == 0 && *source {
+		return errors.New("the source needs -neighbors")
+	}
+	t, err := ListenUDP(*listen)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	links := make([]*Link, len(neighbors))
+	for i, addr := range neighbors {
+		links[i] = &Link{addr: addr, ch: newChannel()}
+	}
+	p := NewLivePeer(*id, t, links, cfg, peerRNG(*id))
+	if *source {
+		if err := p.RunSource(); err != nil {
+			return err
+		}
+		fmt.Printf("peer %d (%s): source, sent %d symbols as %d packets\n", *id, t.Addr(), *send, p.sent)
+		return nil
+	}
+	res, err := p.Run(time.Time{}, time.Now().Add(*timeout))
+	if err != nil {
+		return err
+	}
+	fmt.Println(p.summary(res))
+	if !res.ok {
+		return errors.New("not decoded")
+	}
+	return nil
+}
+
+// runLocalMesh runs graph as live peers in one process, each on its own
+// goroutine, with arc losses from the topology where it sets them.
+func runLocalMesh(graph *Graph, newChannel func() Channel, cfg *liveConfig, timeout time.Duration, peerRNG func(int) *rand.Rand) error {
+	network := NewMemNetwork()
+	sp := simParams{channel: newChannel}
+	peers := make([]*LivePeer, graph.n)
+	for i := range peers {
+		t, err := network.Listen("mem:" + graph.name(i))
+		if err != nil {
+			return err
+		}
+		defer t.Close()
+		var links []*Link
+		for _, j := range graph.adj[i] {
+			// Only the loss model applies; the real transport does the timing
+			l := graph.newLink(i, j, sp)
+			l.addr = "mem:" + graph.name(j)
+			links = append(links, l)
+		}
+		peers[i] = NewLivePeer(i, t, links, cfg, peerRNG(i))
+	}
+
+	fmt.Printf("Live mesh: %d peers, %d directed links, in-memory transport, source %s\n", graph.n, graph.Arcs(), graph.name(0))
+	start := time.Now()
+	deadline := start.Add(timeout)
+	decodes := make([]decodeResult, graph.n-1)
+	errs := make([]error, graph.n)
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p *LivePeer) {
+			defer wg.Done()
+			if i == 0 {
+				errs[i] = p.RunSource()
+				return
+			}
+			decodes[i-1], errs[i] = p.Run(start, deadline)
+		}(i, p)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	fmt.Printf("peer 0 (%s): source, sent %d symbols as %d packets\n", peers[0].t.Addr(), cfg.send, peers[0].sent)
+	for i, res := range decodes {
+		fmt.Println(peers[i+1].summary(res))
+	}
+	fmt.Printf("decoded: %d/%d receivers\n", countDecoded(decodes), len(decodes))
+	printCompletionTable([]string{"Live"}, []completion{collectCompletion(decodes)})
+	return nil
+}
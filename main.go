@@ -1,341 +1,756 @@
 package main
 
 import (
-	crand "crypto/rand"
+	"bytes"
+	"encoding/binary"
 	"flag"
 	"fmt"
 	"math/rand"
+	"os"
+	"slices"
 	"sort"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/klauspost/reedsolomon"
-	"gonum.org/v1/gonum/mat"
 )
 
+// Default generation geometry: 64 symbols of 1 kB, 64 kB per generation.
 const (
-	fileSize  = 64 * 1024 // 64 kB
-	chunkSize = 1024      // 1 kB per symbol
-	k         = fileSize / chunkSize
-	numPeers  = 4
-	fanout    = 2 // each peer forwards to 2 random peers
+	defaultK    = 64
+	defaultSize = 1024
 )
 
-// GF represents a Galois Field of size 2^bits
-// For GF(256), uses klauspost/reedsolomon/galois for accurate arithmetic
-// For GF(2^16), uses fallback table
-type GF struct {
-	bits     int
-	size     int
-	mulTable [][]byte
-	gfmul    func(a, b byte) byte
+const sparseTrials = 20 // transfers per setting in the sparse RLNC tradeoff table
+
+// Coding is the configuration everything that codes or decodes works
+// from: the field, and the generation geometry of K source symbols of
+// Size bytes each. It comes from the flags, a file header or a packet
+// header, and is passed along rather than kept in package state.
+type Coding struct {
+	*GF
+	K    int // source symbols per generation
+	Size int // symbol payload bytes, a multiple of the field's word size
 }
 
-func NewGF(bits int) *GF {
-	size := 1 << bits
-	gf := &GF{
-		bits:     bits,
-		size:     size,
-		mulTable: make([][]byte, size),
-		gfmul:    nil,
+// NewCoding builds the field GF(2^bits) with poly (0 for the default)
+// and checks the geometry against it.
+func NewCoding(bits, poly, k, size int) (*Coding, error) {
+	if bits != 1 && bits != 8 && bits != 16 {
+		return nil, fmt.Errorf("field size must be 1, 8 or 16 bits, not %d", bits)
 	}
-
-	// Build multiplication table for any field size
-	for i := 0; i < size; i++ {
-		gf.mulTable[i] = make([]byte, size)
-		for j := 0; j < size; j++ {
-			gf.mulTable[i][j] = byte((i * j) % size)
-		}
+	gf, err := NewGF(bits, poly)
+	if err != nil {
+		return nil, err
+	}
+	if k < 1 || size < 1 || size%gf.WordSize() != 0 {
+		return nil, fmt.Errorf("k and symbol size must be positive, and the symbol size a multiple of %d bytes in GF(2^%d)", gf.WordSize(), bits)
 	}
-	gf.gfmul = func(a, b byte) byte {
-		return gf.mulTable[a][b]
+	return &Coding{GF: gf, K: k, Size: size}, nil
+}
+
+// rsShards is the RS code length: k data plus k parity shards.
+func (c *Coding) rsShards() int {
+	return 2 * c.K
+}
+
+// coeffBytes is the size of a dense coefficient vector over the k source
+// chunks: k field elements, or k bits rounded up to whole bytes in GF(2).
+func (c *Coding) coeffBytes() int {
+	if c.bits == 1 {
+		return (c.K + 7) / 8
 	}
-	return gf
+	return c.K * c.WordSize()
 }
 
-func (gf *GF) Mul(a, b byte) byte {
-	return gf.gfmul(a, b)
+// scheme is the coding scheme a gossip run uses.
+type scheme int
+
+const (
+	schemeRLNC   scheme = iota // random linear combinations, optionally recoded
+	schemeRS                   // Reed-Solomon shards, unique ones forwarded
+	schemePlain                // raw chunks, unique ones forwarded
+	schemeLT                   // LT fountain symbols, peeled, new ones forwarded
+	schemeRaptor               // LT over a precoded source, peeled, new ones forwarded
+)
+
+// String is the scheme's name in reports.
+func (c scheme) String() string {
+	return [...]string{"RLNC", "RS", "Plain", "LT", "Raptor"}[c]
 }
 
+// Symbol is a coded symbol. Coefficients are field elements (uint16 so
+// both GF(2^8) and GF(2^16) fit); Data is read as WordSize-byte words.
+// In GF(2) mode the coefficients are bit-packed into Bits instead.
 type Symbol struct {
-	Coeff []byte // length k (random coefficients)
-	Data  []byte // same length as chunkSize
+	Coeff  []uint16 // length k (random coefficients)
+	Bits   []uint64 // GF(2) only: k coefficient bits, 64 per word
+	Data   []byte   // Coding.Size bytes
+	Seed   uint32   // coefficient seed when Seeded, see seedCoeffs
+	Seeded bool     // the header carries Seed instead of the vector
+	Gen    int      // generation the symbol belongs to
 }
 
 type Msg struct {
 	Sym      Symbol
-	DataOnly []byte // For plain-gossip mode
+	DataOnly []byte // For plain-gossip and RS mode
+	Shard    int    // RS mode: index of the shard in DataOnly
+	Packet   []byte // Sym in the wire format (wire.go) when the run serializes symbols
 }
 
 type Peer struct {
-	id             int
-	inbox          chan Msg
-	outChans       []chan Msg // subset of other peers
-	received       []*Symbol  // innovative symbols collected
-	dupCount       int
-	done           chan struct{} // Signal for shutdown
-	firstInnovTime time.Time     // When this peer received its first innovative symbol
-	gf             *GF           // Galois Field for this peer
-}
-
-func (p *Peer) run(wg *sync.WaitGroup, plain bool, startTime time.Time, lossProb float64) {
-	defer wg.Done()
-	receivedChunks := make(map[string]bool) // Track received chunks in plain mode
-
-	for {
-		select {
-		case msg, ok := <-p.inbox:
-			if !ok {
-				return
-			}
-			if plain {
-				if msg.DataOnly != nil {
-					// Hash the chunk data to use as key
-					key := string(msg.DataOnly)
-					if !receivedChunks[key] {
-						receivedChunks[key] = true
-						p.received = append(p.received, &Symbol{Data: msg.DataOnly})
-						p.forward(msg, lossProb)
-					}
-				}
-				continue
-			}
+	id           int
+	sim          *Sim
+	links        []*Link   // subset of other peers
+	received     []*Symbol // innovative symbols collected
+	dupCount     int       // copies of a symbol already received
+	depCount     int       // RLNC and RS: distinct symbols linearly dependent on those held
+	code         scheme
+	recode       bool               // recode instead of forwarding
+	wire         bool               // RLNC mode: send symbols as wire packets, see Msg.Packet
+	seen         map[string]bool    // Track received chunks in plain mode, symbols in coded modes
+	shards       [][]byte           // RS mode: shards by index, nil if missing
+	firstInnovAt time.Duration      // Virtual time of the first innovative symbol, -1 if none
+	fullRankAt   time.Duration      // Virtual time rank k was reached, -1 if never
+	recvCount    int                // messages delivered, innovative or not
+	recvBytes    int                // bytes delivered, coefficient headers included
+	recvHeader   int                // coefficient header bytes delivered
+	rankCount    int                // recvCount when rank k was reached
+	rankDep      int                // dependent symbols before rank k, all of them if never reached
+	c            *Coding            // field and generation geometry
+	dec          *GenerationDecoder // RLNC mode
+	peel         *PeelingDecoder    // LT/Raptor mode
+	dash         *Dashboard         // receives the peer's events with -serve, nil otherwise
+	rng          *rand.Rand         // shared run-wide source, for reproducibility
 
-			if p.isInnovative(&msg.Sym) {
-				if len(p.received) == 0 {
-					p.firstInnovTime = time.Now()
-				}
-				p.received = append(p.received, &msg.Sym)
-				p.forward(msg, lossProb)
-				if len(p.received) == k {
-					// done, but keep channel draining to avoid goroutine leak
-				}
+	// Live peers (livepeer.go) send wire packets over t in wall-clock
+	// time instead of scheduling them on sim, which is then nil.
+	t       Transport
+	start   time.Time // zero of the live peer's clock, see now
+	sent    int       // packets handed to t
+	corrupt int       // wire packets Unmarshal rejected
+	err     error     // first error sending over t
+}
+
+func NewPeer(id int, sim *Sim, c *Coding, rng *rand.Rand, code scheme, recode bool) *Peer {
+	p := &Peer{
+		id:           id,
+		sim:          sim,
+		rng:          rng,
+		code:         code,
+		recode:       recode,
+		firstInnovAt: -1,
+		fullRankAt:   -1,
+		c:            c,
+	}
+	switch code {
+	case schemeRLNC:
+		p.seen = make(map[string]bool)
+		p.dec = NewGenerationDecoder(1, func() RankDecoder { return NewProgressiveDecoder(c.GF, c.K) })
+	case schemeRS:
+		p.shards = make([][]byte, c.rsShards())
+	case schemePlain:
+		p.seen = make(map[string]bool)
+	case schemeLT, schemeRaptor:
+		p.seen = make(map[string]bool)
+		p.peel = NewPeelingDecoder(c.K, c.Size, code == schemeRaptor)
+	}
+	return p
+}
+
+// receive handles one delivered message. With recode set, each innovative
+// symbol triggers fresh random combinations of everything the peer holds
+// (one per neighbor) instead of forwarding the received symbol as is.
+func (p *Peer) receive(msg Msg) {
+	p.recvCount++
+	if msg.Packet != nil {
+		sym, err := unmarshalFor(msg.Packet, p.c, p.dec.Generations())
+		if err != nil {
+			// Only a real network corrupts packets; the simulated links never do
+			p.recvBytes += len(msg.Packet)
+			p.corrupt++
+			return
+		}
+		msg.Sym = sym
+	}
+	p.recvBytes += msg.wireSize(p.c)
+	p.recvHeader += msg.headerSize(p.c)
+	switch p.code {
+	case schemePlain:
+		if msg.DataOnly != nil {
+			// Hash the chunk data to use as key
+			key := string(msg.DataOnly)
+			if !p.seen[key] {
+				p.seen[key] = true
+				p.accept(&Symbol{Data: msg.DataOnly}, len(p.received)+1 == p.c.K)
+				p.forward(msg)
 			} else {
-				p.dupCount++
+				p.emit("duplicate", -1)
 			}
-		case <-p.done:
+		}
+	case schemeRS:
+		if p.shards[msg.Shard] != nil {
+			p.duplicate()
+			return
+		}
+		p.shards[msg.Shard] = msg.DataOnly
+		// Any k distinct shards recover the file, so once the peer holds k
+		// a new one is dependent on them; it is still kept and relayed
+		if p.complete() {
+			p.dependent()
+		} else {
+			p.accept(&Symbol{Data: msg.DataOnly}, len(p.received)+1 == p.c.K)
+		}
+		p.forward(msg)
+	case schemeLT, schemeRaptor:
+		// Symbols with the same neighbor set carry the same data, so the
+		// set identifies a symbol; without this, pending symbols would
+		// bounce around cycles forever.
+		key := bitsKey(msg.Sym.Bits)
+		if p.seen[key] {
+			p.duplicate()
+			return
+		}
+		p.seen[key] = true
+		if !p.peel.Add(&msg.Sym) {
+			p.duplicate()
+			return
+		}
+		p.accept(&msg.Sym, p.peel.Complete())
+		p.forward(msg)
+	default:
+		// Regenerate seeded coefficients for the decoder. Seeded stays set,
+		// so forwarding still sends only the seed; recoding below mixes the
+		// vectors into a new one, which has no seed and goes out explicit.
+		msg.Sym.expand(p.c)
+		key := coeffKey(&msg.Sym)
+		if p.seen[key] {
+			p.duplicate()
 			return
 		}
+		p.seen[key] = true
+		if !p.isInnovative(&msg.Sym) {
+			p.dependent()
+			return
+		}
+		p.accept(&msg.Sym, p.dec.Complete())
+		if p.recode {
+			p.recodeAll(msg.Sym.Gen)
+		} else {
+			p.forward(msg)
+		}
 	}
 }
 
-func (p *Peer) forward(msg Msg, lossProb float64) {
-	for _, ch := range p.outChans {
-		// Simulate packet loss
-		if rand.Float64() < lossProb {
-			continue
+// accept records an innovative symbol; complete says whether it gave the
+// peer the whole file.
+func (p *Peer) accept(sym *Symbol, complete bool) {
+	if len(p.received) == 0 {
+		p.firstInnovAt = p.now()
+	}
+	p.received = append(p.received, sym)
+	p.emit("innovative", -1)
+	if complete {
+		p.fullRankAt = p.now()
+		p.rankCount = p.recvCount
+		p.emit("complete", -1)
+	}
+}
+
+// duplicate counts a received message that added nothing.
+func (p *Peer) duplicate() {
+	p.dupCount++
+	p.emit("duplicate", -1)
+}
+
+// dependent counts a new symbol that added nothing: an RLNC symbol whose
+// coefficient vector is a combination of ones the peer already holds, or
+// an RS shard beyond the k that already recover the file.
+func (p *Peer) dependent() {
+	p.depCount++
+	if p.fullRankAt < 0 {
+		p.rankDep++
+	}
+	p.emit("dependent", -1)
+}
+
+// emit reports an event to the dashboard, if there is one. Message
+// events go from this peer to peer to; events at the peer pass -1.
+func (p *Peer) emit(kind string, to int) {
+	if p.dash == nil {
+		return
+	}
+	ev := Event{T: float64(p.now()) / float64(time.Millisecond), Kind: kind, From: p.id, Peer: p.id, Rank: len(p.received)}
+	if to >= 0 {
+		ev.Peer = to
+	}
+	p.dash.Emit(ev)
+}
+
+// now is the peer's clock: virtual time in the simulator, wall-clock
+// time since start for a live peer.
+func (p *Peer) now() time.Duration {
+	if p.t != nil {
+		return time.Since(p.start)
+	}
+	return p.sim.Now()
+}
+
+// complete reports whether the peer holds the whole file (rank k, k
+// distinct RS shards, all k chunks peeled, or all k chunks in plain mode).
+func (p *Peer) complete() bool {
+	return p.fullRankAt >= 0
+}
+
+func (p *Peer) forward(msg Msg) {
+	for _, l := range p.links {
+		p.send(l, msg)
+	}
+}
+
+// recodeAll sends each neighbor its own random combination of the
+// symbols of generation gen received so far.
+func (p *Peer) recodeAll(gen int) {
+	var held []*Symbol
+	for _, s := range p.received {
+		if s.Gen == gen {
+			held = append(held, s)
 		}
-		select {
-		case ch <- msg:
-		default:
-			// Drop message if channel is full
+	}
+	for _, l := range p.links {
+		sym := recodeSymbol(held, p.c, p.rng)
+		sym.Gen = gen
+		p.send(l, Msg{Sym: sym})
+	}
+}
+
+// send schedules delivery of msg over l. The message occupies the link
+// for its serialization time even if the channel then loses it.
+func (p *Peer) send(l *Link, msg Msg) {
+	if p.wire && msg.Packet == nil {
+		pkt, err := Marshal(&msg.Sym, p.c)
+		if err != nil {
+			panic(err)
 		}
+		msg.Packet = pkt
 	}
+	if p.t != nil {
+		// Live: the real network does the timing
+		if l.ch.Drop(p.rng) {
+			return
+		}
+		if err := p.t.Send(l.addr, msg.Packet); err != nil && p.err == nil {
+			p.err = fmt.Errorf("send to %s: %w", l.addr, err)
+		}
+		p.sent++
+		return
+	}
+	d := l.transit(p.sim.Now(), msg.wireSize(p.c), p.rng)
+	// Simulate packet loss
+	if l.ch.Drop(p.rng) {
+		p.emit("lost", l.to.id)
+		return
+	}
+	p.emit("sent", l.to.id)
+	p.sim.Schedule(d, func() { l.to.receive(msg) })
 }
 
+// isInnovative inserts the symbol into the peer's progressive decoder and
+// reports whether it increased the rank.
 func (p *Peer) isInnovative(sym *Symbol) bool {
-	rows := len(p.received) + 1
-	matData := make([]float64, rows*k)
-	for i, s := range append(p.received, sym) {
-		for j, b := range s.Coeff {
-			matData[i*k+j] = float64(b)
+	return p.dec.Add(sym)
+}
+
+// coeffKey identifies an RLNC symbol by its generation and coefficient
+// vector; two deliveries with the same key are copies of one symbol.
+func coeffKey(sym *Symbol) string {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(sym.Gen))
+	if sym.Bits != nil {
+		return string(b) + bitsKey(sym.Bits)
+	}
+	for _, v := range sym.Coeff {
+		b = binary.LittleEndian.AppendUint16(b, v)
+	}
+	return string(b)
+}
+
+func encodeFile(c *Coding, rng *rand.Rand) (src []byte, symbols []Symbol) {
+	src = make([]byte, c.K*c.Size)
+	rng.Read(src)
+	symbols = make([]Symbol, c.K)
+	for i := range symbols {
+		symbols[i].Data = src[i*c.Size : (i+1)*c.Size]
+	}
+	return src, symbols
+}
+
+// decodeResult is the outcome of one peer decoding its received symbols,
+// together with its completion metrics.
+type decodeResult struct {
+	peer         int
+	ok           bool // reconstructed bytes match the original file
+	err          error
+	duration     time.Duration // wall-clock decode time
+	timeToRank   time.Duration // virtual time at which rank k was reached, -1 if never
+	timeToDecode time.Duration // time the peer held the verified file: timeToRank, -1 if not decoded
+	symbols      int           // symbols received by the time rank k was reached
+	bytes        int           // total bytes received, coefficient headers included
+	headerBytes  int           // coefficient header bytes among them
+	dependent    int           // RLNC: distinct symbols received that were linearly dependent
+	rankDep      int           // RLNC: those received before rank k was reached
+	genRanks     []int         // RLNC: rank reached in each generation
+	ops          int           // payload row operations the decode took, see Decoder.Ops
+}
+
+// encodeRS splits src into k data shards and appends k parity shards.
+func encodeRS(c *Coding, src []byte) (reedsolomon.Encoder, [][]byte) {
+	enc, err := reedsolomon.New(c.K, c.rsShards()-c.K)
+	if err != nil {
+		panic(err)
+	}
+	shards := make([][]byte, c.rsShards())
+	for i := range shards {
+		shards[i] = make([]byte, c.Size)
+		if i < c.K {
+			copy(shards[i], src[i*c.Size:(i+1)*c.Size])
 		}
 	}
-	m := mat.NewDense(rows, k, matData)
-	var svd mat.SVD
-	ok := svd.Factorize(m, mat.SVDThin)
-	if !ok {
-		return false
+	if err := enc.Encode(shards); err != nil {
+		panic(err)
 	}
-	rank := 0
-	vals := svd.Values(nil)
-	// Use a more lenient threshold for rank computation
-	threshold := 1e-6
-	for _, v := range vals {
-		if v > threshold {
-			rank++
+	return enc, shards
+}
+
+// withMetrics adds the completion metrics p tracked while receiving to
+// its decode result.
+func (p *Peer) withMetrics(res decodeResult) decodeResult {
+	res.timeToRank, res.symbols, res.bytes, res.headerBytes = p.fullRankAt, p.rankCount, p.recvBytes, p.recvHeader
+	res.dependent, res.rankDep = p.depCount, p.rankDep
+	res.timeToDecode = -1
+	if res.ok {
+		// Decoding takes no virtual time; its CPU time is res.duration
+		res.timeToDecode = p.fullRankAt
+	}
+	return res
+}
+
+// decodeRS rebuilds the missing shards the peer did not receive with
+// Reconstruct and checks the data shards against src.
+func decodeRS(p *Peer, enc reedsolomon.Encoder, src []byte) decodeResult {
+	shards := make([][]byte, len(p.shards))
+	copy(shards, p.shards)
+	start := time.Now()
+	err := enc.Reconstruct(shards)
+	res := decodeResult{peer: p.id, err: err, duration: time.Since(start)}
+	res.ok = err == nil && bytes.Equal(bytes.Join(shards[:p.c.K], nil), src)
+	return res
+}
+
+// decodeLT peels the peer's LT or Raptor symbols from scratch and checks
+// the result against src.
+func decodeLT(p *Peer, precode bool, src []byte) decodeResult {
+	start := time.Now()
+	dec := NewPeelingDecoder(p.c.K, p.c.Size, precode)
+	for _, s := range p.received {
+		dec.Add(s)
+	}
+	out, err := dec.Data()
+	res := decodeResult{peer: p.id, err: err, duration: time.Since(start)}
+	res.ok = err == nil && bytes.Equal(out, src)
+	return res
+}
+
+// decodePeer runs Gaussian elimination over the peer's symbols, one
+// generation at a time, and checks the result against src (which the
+// padded output may extend). Sparse symbols go through SparseDecoder,
+// whose inserts are part of the decode, so the timing covers them too.
+func decodePeer(p *Peer, sparse bool, src []byte) decodeResult {
+	byGen := make([][]*Symbol, p.dec.Generations())
+	for _, s := range p.received {
+		byGen[s.Gen] = append(byGen[s.Gen], s)
+	}
+	res := decodeResult{peer: p.id}
+	var out []byte
+	for g, syms := range byGen {
+		b, ops, dur, err := decodeGeneration(p.c, syms, sparse)
+		res.duration += dur
+		res.ops += ops
+		if err != nil && len(byGen) > 1 {
+			err = fmt.Errorf("generation %d: %w", g, err)
+		}
+		if err != nil {
+			res.err = err
+			return res
 		}
+		out = append(out, b...)
 	}
-	return rank == len(p.received)+1
+	res.ok = bytes.Equal(out[:len(src)], src)
+	return res
 }
 
-func encodeFile() []Symbol {
-	src := make([]byte, fileSize)
-	crand.Read(src)
-	symbols := make([]Symbol, k)
-	for i := 0; i < k; i++ {
-		symbols[i].Data = src[i*chunkSize : (i+1)*chunkSize]
+// decodeGeneration decodes the symbols of one generation.
+func decodeGeneration(c *Coding, syms []*Symbol, sparse bool) (out []byte, ops int, dur time.Duration, err error) {
+	if sparse {
+		start := time.Now()
+		dec := NewSparseDecoder(c.GF, c.K)
+		for _, s := range syms {
+			dec.Add(s)
+		}
+		out, err = dec.Data()
+		return out, dec.Ops(), time.Since(start), err
 	}
-	return symbols
+	dec := NewDecoder(c.GF, c.K)
+	for _, s := range syms {
+		dec.Add(s)
+	}
+	start := time.Now()
+	out, err = dec.Decode()
+	return out, dec.Ops(), time.Since(start), err
 }
 
-func makeCoeff(gf *GF) byte {
-	return byte(rand.Intn(gf.size))
+func makeCoeff(gf *GF, rng *rand.Rand) uint16 {
+	return uint16(rng.Intn(gf.size))
 }
 
-func mixSymbol(src []Symbol, gf *GF) Symbol {
-	coeff := make([]byte, k)
-	data := make([]byte, chunkSize)
+func mixSymbol(src []Symbol, c *Coding, rng *rand.Rand) Symbol {
+	if c.bits == 1 {
+		return mixBinary(src, c, rng)
+	}
+	coeff := make([]uint16, c.K)
+	data := make([]byte, c.Size)
 
 	// Ensure at least one non-zero coefficient
 	hasNonZero := false
 	for i := range coeff {
-		c := makeCoeff(gf)
-		coeff[i] = c
-		if c != 0 {
+		coeff[i] = makeCoeff(c.GF, rng)
+		if coeff[i] != 0 {
 			hasNonZero = true
 		}
 	}
 
 	// If all coefficients are zero, set one to 1
 	if !hasNonZero {
-		coeff[rand.Intn(k)] = 1
+		coeff[rng.Intn(c.K)] = 1
 	}
 
 	// Mix the data
 	for i := range coeff {
-		if coeff[i] != 0 {
-			for j := range data {
-				data[j] ^= gf.Mul(src[i].Data[j], coeff[i])
-			}
-		}
+		c.MulAdd(data, src[i].Data, coeff[i])
 	}
 
 	return Symbol{Coeff: coeff, Data: data}
 }
 
-func simulate(plain bool, lossProb float64, fieldBits int) (avgInnov, avgDup float64, latencies []time.Duration) {
-	srcSyms := encodeFile()
-	startTime := time.Now()
-	gf := NewGF(fieldBits)
+// systematicSymbol returns source chunk i as a symbol with the unit
+// coefficient vector e_i, which every decoder absorbs without payload work.
+func systematicSymbol(src []Symbol, i int, c *Coding) Symbol {
+	if c.bits == 1 {
+		vec := make([]uint64, packedWords(c.K))
+		vec[i/64] |= 1 << (i % 64)
+		return Symbol{Bits: vec, Data: src[i].Data}
+	}
+	coeff := make([]uint16, c.K)
+	coeff[i] = 1
+	return Symbol{Coeff: coeff, Data: src[i].Data}
+}
 
-	// Initialize peers with larger buffers
-	peers := make([]*Peer, numPeers)
-	for i := 0; i < numPeers; i++ {
-		peers[i] = &Peer{
-			id:       i,
-			inbox:    make(chan Msg, 10000), // Increased buffer size
-			outChans: make([]chan Msg, 0),
-			done:     make(chan struct{}),
-			gf:       gf,
+// isSystematic reports whether s is an uncoded source chunk, i.e. its
+// coefficient vector is a unit vector of length k.
+func isSystematic(s *Symbol, k int) bool {
+	ones := 0
+	for i := 0; i < k; i++ {
+		switch s.coeffAt(i) {
+		case 0:
+		case 1:
+			ones++
+		default:
+			return false
 		}
 	}
+	return ones == 1
+}
 
-	// Set up peer connections
-	for _, p := range peers {
-		for len(p.outChans) < fanout {
-			q := peers[rand.Intn(numPeers)]
-			if q != p {
-				p.outChans = append(p.outChans, q.inbox)
-			}
+// recodeSymbol returns a random linear combination of already coded
+// symbols. Both the coefficient vectors and the payloads are combined, so
+// the result is still expressed over the original k source chunks.
+func recodeSymbol(held []*Symbol, c *Coding, rng *rand.Rand) Symbol {
+	if c.bits == 1 {
+		return recodeBinary(held, c, rng)
+	}
+	r := make([]uint16, len(held))
+	hasNonZero := false
+	for i := range r {
+		r[i] = makeCoeff(c.GF, rng)
+		if r[i] != 0 {
+			hasNonZero = true
 		}
 	}
-
-	// Reset peers and start goroutines
-	var wg sync.WaitGroup
-	for _, p := range peers {
-		p.received, p.dupCount = nil, 0
-		wg.Add(1)
-		go p.run(&wg, plain, startTime, lossProb)
+	if !hasNonZero {
+		r[rng.Intn(len(r))] = 1
 	}
 
-	// Inject data from peer 0
-	if plain {
-		for _, s := range srcSyms {
-			peers[0].forward(Msg{DataOnly: s.Data}, lossProb)
+	coeff := make([]uint16, c.K)
+	data := make([]byte, c.Size)
+	for i, s := range held {
+		if r[i] == 0 {
+			continue
 		}
-	} else {
-		// Send more mixes to ensure enough innovative symbols
-		for i := 0; i < k*3; i++ { // Increased from k*2 to k*3
-			peers[0].forward(Msg{Sym: mixSymbol(srcSyms, gf)}, lossProb)
+		for j := range coeff {
+			coeff[j] ^= c.Mul(r[i], s.Coeff[j])
 		}
+		c.MulAdd(data, s.Data, r[i])
 	}
+	return Symbol{Coeff: coeff, Data: data}
+}
 
-	time.Sleep(2 * time.Second) // simple "quiesce"
+// simParams collects the network settings shared by the gossip runs.
+type simParams struct {
+	graph      *Graph
+	channel    func() Channel // default loss model for links the topology does not override
+	delay      time.Duration
+	jitter     Jitter
+	bandwidth  float64 // bits per second, 0 for unlimited
+	recode     bool
+	systematic bool       // RLNC source sends the k chunks uncoded before the repair symbols
+	sparsity   sparsity   // RLNC source coefficient density; peers decode with SparseDecoder unless dense
+	seeded     bool       // RLNC source sends coefficient seeds instead of vectors
+	input      []byte     // RLNC source data, split into generations; nil for one random generation
+	wire       bool       // RLNC symbols travel as wire packets, framing and CRC included
+	dash       *Dashboard // streams the run in paced virtual time with -serve, nil otherwise
+}
 
-	// Signal shutdown to all peers
-	for _, p := range peers {
-		close(p.done)
+// simulate runs the gossip mesh on the discrete-event simulator with the
+// given coding scheme. Only RLNC peers recode. The run ends as soon as
+// every receiver holds the file or no traffic is left; latencies are in
+// virtual time. The results cover the receivers only: the source holds
+// the file from the start, whether or not any arc leads back to it.
+func simulate(code scheme, sp simParams, c *Coding, rng *rand.Rand) (avgInnov, avgDup float64, latencies []time.Duration, decodes []decodeResult) {
+	src, srcSyms := encodeFile(c, rng)
+	gens := [][]Symbol{srcSyms}
+	if sp.input != nil {
+		src, gens = sp.input, c.splitGenerations(sp.input)
 	}
+	sim := NewSim()
 
-	wg.Wait()
-
-	// Tally results
-	for _, p := range peers {
-		avgInnov += float64(len(p.received))
-		avgDup += float64(p.dupCount)
-		if !p.firstInnovTime.IsZero() {
-			latencies = append(latencies, p.firstInnovTime.Sub(startTime))
+	sparse := !sp.sparsity.dense()
+	newDec := func() RankDecoder {
+		if sparse {
+			return NewSparseDecoder(c.GF, c.K)
 		}
+		return NewProgressiveDecoder(c.GF, c.K)
 	}
-	avgInnov /= float64(numPeers)
-	avgDup /= float64(numPeers)
-	return
-}
-
-func simulateRS(lossProb float64) (avgInnov, avgDup float64, latencies []time.Duration) {
-	// RS parameters
-	n := k * 2 // n = 2k for redundancy
-	enc, err := reedsolomon.New(k, n-k)
-	if err != nil {
-		panic(err)
+	peers := make([]*Peer, sp.graph.n)
+	for i := range peers {
+		peers[i] = NewPeer(i, sim, c, rng, code, sp.recode && code == schemeRLNC)
+		peers[i].wire = sp.wire && code == schemeRLNC
+		if code == schemeRLNC && (sparse || len(gens) > 1) {
+			peers[i].dec = NewGenerationDecoder(len(gens), newDec)
+		}
 	}
 
-	src := make([]byte, fileSize)
-	crand.Read(src)
-	blocks := make([][]byte, k)
-	for i := 0; i < k; i++ {
-		blocks[i] = src[i*chunkSize : (i+1)*chunkSize]
-	}
-	shards := make([][]byte, n)
-	for i := 0; i < k; i++ {
-		shards[i] = make([]byte, chunkSize)
-		copy(shards[i], blocks[i])
-	}
-	for i := k; i < n; i++ {
-		shards[i] = make([]byte, chunkSize)
-	}
-	if err := enc.Encode(shards); err != nil {
-		panic(err)
+	if sp.dash != nil {
+		need := c.K
+		if code == schemeRLNC {
+			need = c.K * len(gens)
+		}
+		sp.dash.Start(code.String(), sp.graph, need)
+		sim.Pace(sp.dash.speed)
+		for _, p := range peers {
+			p.dash = sp.dash
+		}
 	}
 
-	// Simulate peers
-	peers := make([]map[string]bool, numPeers)
-	dupCounts := make([]int, numPeers)
-	firstTimes := make([]time.Time, numPeers)
-	startTime := time.Now()
+	// Set up peer connections
+	for i, p := range peers {
+		for _, j := range sp.graph.adj[i] {
+			l := sp.graph.newLink(i, j, sp)
+			l.to = peers[j]
+			p.links = append(p.links, l)
+		}
+	}
 
-	// Each peer receives shards via lossy forwarding
-	for i := 0; i < n; i++ {
-		for p := 0; p < numPeers; p++ {
-			if rand.Float64() < lossProb {
-				continue
-			}
-			if peers[p] == nil {
-				peers[p] = make(map[string]bool)
-			}
-			key := string(shards[i])
-			if !peers[p][key] {
-				peers[p][key] = true
-				if len(peers[p]) == 1 {
-					firstTimes[p] = time.Now()
+	// Inject data from peer 0
+	var rs reedsolomon.Encoder
+	switch code {
+	case schemePlain:
+		for _, s := range srcSyms {
+			peers[0].forward(Msg{DataOnly: s.Data})
+		}
+	case schemeRS:
+		var shards [][]byte
+		rs, shards = encodeRS(c, src)
+		for i, s := range shards {
+			peers[0].forward(Msg{DataOnly: s, Shard: i})
+		}
+	case schemeLT, schemeRaptor:
+		// Rateless: send as many symbols as RLNC sends mixes
+		enc := NewLTEncoder(srcSyms, code == schemeRaptor, rng)
+		for i := 0; i < c.K*3; i++ {
+			peers[0].forward(Msg{Sym: enc.Next()})
+		}
+	default:
+		// Send more mixes to ensure enough innovative symbols; in
+		// systematic mode the first k are the source chunks themselves.
+		// Generations go out one after the other.
+		for g, srcSyms := range gens {
+			for i := 0; i < c.K*3; i++ {
+				var sym Symbol
+				if sp.systematic && i < c.K {
+					sym = systematicSymbol(srcSyms, i, c)
+				} else if sp.seeded {
+					sym = mixSeeded(srcSyms, c, rng)
+				} else if sparse {
+					sym = mixSparse(srcSyms, c, sp.sparsity, rng)
+				} else {
+					sym = mixSymbol(srcSyms, c, rng)
 				}
-			} else {
-				dupCounts[p]++
+				sym.Gen = g
+				peers[0].forward(Msg{Sym: sym})
 			}
 		}
 	}
 
+	receivers := peers[1:]
+	sim.Run(func() bool {
+		for _, p := range receivers {
+			if !p.complete() {
+				return false
+			}
+		}
+		return true
+	})
+
 	// Tally results
-	for p := 0; p < numPeers; p++ {
-		avgInnov += float64(len(peers[p]))
-		avgDup += float64(dupCounts[p])
-		if !firstTimes[p].IsZero() {
-			latencies = append(latencies, firstTimes[p].Sub(startTime))
+	for _, p := range receivers {
+		avgInnov += float64(len(p.received))
+		avgDup += float64(p.dupCount)
+		if p.firstInnovAt >= 0 {
+			latencies = append(latencies, p.firstInnovAt)
+		}
+		var res decodeResult
+		switch code {
+		case schemePlain:
+			// Plain peers hold raw chunks, so holding all k is decoding
+			res = decodeResult{peer: p.id, ok: p.complete()}
+		case schemeRS:
+			res = decodeRS(p, rs, src)
+		case schemeLT, schemeRaptor:
+			res = decodeLT(p, code == schemeRaptor, src)
+		default:
+			res = decodePeer(p, sparse, src)
+			for g := 0; g < p.dec.Generations(); g++ {
+				res.genRanks = append(res.genRanks, p.dec.GenRank(g))
+			}
 		}
+		decodes = append(decodes, p.withMetrics(res))
+	}
+	if sp.dash != nil {
+		sp.dash.Finish(decodes)
+	}
+	if len(receivers) > 0 {
+		avgInnov /= float64(len(receivers))
+		avgDup /= float64(len(receivers))
 	}
-	avgInnov /= float64(numPeers)
-	avgDup /= float64(numPeers)
 	return
 }
 
@@ -351,80 +766,121 @@ func computeLatencyStats(latencies []time.Duration) (p50, p95 time.Duration) {
 	return
 }
 
-func simulateMultihopRLNC(lossProb float64, fieldBits int, hops int) int {
-	gf := NewGF(fieldBits)
-	srcSyms := encodeFile()
-	curr := make([]Symbol, k*2)
-	for i := 0; i < k*2; i++ {
-		curr[i] = mixSymbol(srcSyms, gf)
+func countDecoded(decodes []decodeResult) int {
+	n := 0
+	for _, d := range decodes {
+		if d.ok {
+			n++
+		}
+	}
+	return n
+}
+
+// rankLatencies collects time-to-full-rank of the peers that got there.
+func rankLatencies(decodes []decodeResult) []time.Duration {
+	var lat []time.Duration
+	for _, d := range decodes {
+		if d.timeToRank >= 0 {
+			lat = append(lat, d.timeToRank)
+		}
 	}
+	return lat
+}
+
+// printDecodes lists each peer's decode outcome; large meshes only get
+// the summary line.
+func printDecodes(decodes []decodeResult) {
+	if len(decodes) > 16 {
+		return
+	}
+	for _, d := range decodes {
+		switch {
+		case d.ok:
+			fmt.Printf("       peer %d: decoded OK in %v\n", d.peer, d.duration)
+		case d.err != nil:
+			fmt.Printf("       peer %d: decode failed: %v\n", d.peer, d.err)
+		default:
+			fmt.Printf("       peer %d: decoded data does not match source\n", d.peer)
+		}
+	}
+}
+
+// simulateMultihopRLNC sends 2k coded symbols down a chain of lossy hops.
+// Every relay recodes whatever survived into 2k fresh combinations. In
+// systematic mode the source sends the k chunks uncoded plus k repair
+// symbols, and relays pass surviving source chunks through unchanged,
+// recoding only the rest. It returns the destination's rank over the GF
+// (at most k), whether the file was decoded and verified, how many
+// packets arrived at each hop and the payload row operations the
+// destination's decoder performed.
+func simulateMultihopRLNC(newChannel func() Channel, c *Coding, hops int, systematic bool, rng *rand.Rand) (rank int, decoded bool, perHop []int, ops int) {
+	src, srcSyms := encodeFile(c, rng)
+	curr := make([]Symbol, c.K*2)
+	for i := 0; i < c.K*2; i++ {
+		if systematic && i < c.K {
+			curr[i] = systematicSymbol(srcSyms, i, c)
+		} else {
+			curr[i] = mixSymbol(srcSyms, c, rng)
+		}
+	}
+	var arrived []*Symbol
 	for h := 0; h < hops; h++ {
 		// Apply loss
-		next := make([]Symbol, 0, len(curr))
-		for _, s := range curr {
-			if rand.Float64() >= lossProb {
-				next = append(next, s)
+		ch := newChannel()
+		arrived = make([]*Symbol, 0, len(curr))
+		for i := range curr {
+			if !ch.Drop(rng) {
+				arrived = append(arrived, &curr[i])
 			}
 		}
-		// RLNC recoding: generate new random mixes from what survived
-		if len(next) < k {
-			// Not enough to recode, break early
-			curr = next
+		perHop = append(perHop, len(arrived))
+		if h == hops-1 || len(arrived) == 0 {
 			break
 		}
-		curr = make([]Symbol, k*2)
-		for i := 0; i < k*2; i++ {
-			curr[i] = mixSymbol(next, gf)
-		}
-	}
-	// Count innovative at destination
-	received := make([]*Symbol, 0, len(curr))
-	for i := range curr {
-		innov := true
-		for _, s := range received {
-			if !isInnovativePair(s, &curr[i]) {
-				innov = false
-				break
+		// RLNC recoding: new random mixes of what survived, still
+		// expressed over the source chunks
+		next := make([]Symbol, 0, c.K*2)
+		if systematic {
+			for _, s := range arrived {
+				if isSystematic(s, c.K) {
+					next = append(next, *s)
+				}
 			}
 		}
-		if innov {
-			received = append(received, &curr[i])
+		for len(next) < c.K*2 {
+			next = append(next, recodeSymbol(arrived, c, rng))
 		}
+		curr = next
 	}
-	return len(received)
-}
 
-func simulateMultihopRS(lossProb float64, hops int) int {
-	enc, err := reedsolomon.New(k, k)
-	if err != nil {
-		panic(err)
-	}
-	src := make([]byte, fileSize)
-	crand.Read(src)
-	blocks := make([][]byte, k)
-	for i := 0; i < k; i++ {
-		blocks[i] = src[i*chunkSize : (i+1)*chunkSize]
-	}
-	shards := make([][]byte, k*2)
-	for i := 0; i < k; i++ {
-		shards[i] = make([]byte, chunkSize)
-		copy(shards[i], blocks[i])
-	}
-	for i := k; i < k*2; i++ {
-		shards[i] = make([]byte, chunkSize)
-	}
-	if err := enc.Encode(shards); err != nil {
-		panic(err)
+	// Decode at destination; rank over the GF can never exceed k
+	dec := NewProgressiveDecoder(c.GF, c.K)
+	for _, s := range arrived {
+		dec.Add(s)
 	}
+	out, err := dec.Data()
+	return dec.Rank(), err == nil && bytes.Equal(out, src), perHop, dec.Ops()
+}
+
+// simulateMultihopRS sends the 2k RS shards down the same chain without
+// any recoding. It returns the number of unique shards at the destination
+// and the packets that arrived at each hop; the file is decodable iff at
+// least k unique shards arrive.
+func simulateMultihopRS(newChannel func() Channel, c *Coding, hops int, rng *rand.Rand) (unique int, perHop []int) {
+	src := make([]byte, c.K*c.Size)
+	rng.Read(src)
+	_, shards := encodeRS(c, src)
 	curr := shards
 	for h := 0; h < hops; h++ {
 		// Apply loss
+		ch := newChannel()
 		next := make([][]byte, 0, len(curr))
 		for _, s := range curr {
-			if rand.Float64() >= lossProb {
+			if !ch.Drop(rng) {
 				next = append(next, s)
 			}
 		}
+		perHop = append(perHop, len(next))
 		curr = next
 	}
 	// Count unique blocks at destination
@@ -432,83 +888,320 @@ func simulateMultihopRS(lossProb float64, hops int) int {
 	for _, s := range curr {
 		seen[string(s)] = struct{}{}
 	}
-	return len(seen)
+	return len(seen), perHop
 }
 
-// Helper for innovation check in multihop RLNC
-func isInnovativePair(a, b *Symbol) bool {
-	for i := range a.Coeff {
-		if a.Coeff[i] != b.Coeff[i] {
-			return true
+func main() {
+	// encode and decode are the file tool (filetool.go), peer runs a live
+	// peer (livepeer.go); anything else runs the simulator. Errors go to stderr since decode may write to stdout.
+	if len(os.Args) > 1 {
+		var run func([]string) error
+		switch os.Args[1] {
+		case "encode":
+			run = runEncode
+		case "decode":
+			run = runDecode
+		case "peer":
+			run = runPeer
+		}
+		if run != nil {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			return
 		}
 	}
-	return false
-}
 
-func main() {
 	// Parse command line flags
 	lossProb := flag.Float64("loss", 0.0, "Packet loss probability (0.0 to 1.0)")
-	fieldBits := flag.Int("field", 8, "Number of bits for Galois Field (8 or 16)")
-	codeType := flag.String("code", "rlnc", "Coding scheme: rlnc, rs, or plain")
-	compare := flag.Bool("compare", false, "Compare RLNC, RS, and plain side by side")
+	channelSpec := flag.String("channel", "", "Loss model: bernoulli[:P] or ge:P,R[,LossGood,LossBad] for Gilbert–Elliott bursts (default bernoulli with -loss)")
+	fieldBits := flag.Int("field", 8, "Number of bits for Galois Field (1, 8 or 16; 1 = binary RLNC)")
+	codeType := flag.String("code", "rlnc", "Coding scheme: rlnc, rs, plain, lt or raptor")
+	compare := flag.Bool("compare", false, "Compare RLNC, RS, plain, LT and Raptor side by side")
 	multihop := flag.Bool("multihop", false, "Run multi-hop chain simulation for RLNC and RS")
 	hops := flag.Int("hops", 3, "Number of hops for multi-hop simulation")
+	recode := flag.Bool("recode", false, "Recode at intermediate peers instead of forwarding received symbols")
+	systematic := flag.Bool("systematic", false, "Systematic RLNC: the source sends the k chunks uncoded before coded repair symbols")
+	density := flag.Float64("density", 1, "Sparse RLNC: probability that each source coefficient is non-zero (1 = dense)")
+	degree := flag.Int("degree", 0, "Sparse RLNC: exactly this many non-zero source coefficients per symbol (overrides -density; 0 = off)")
+	seedCoeff := flag.Bool("seedcoeff", false, "Send source coefficient vectors as a 4-byte PRNG seed; recoded symbols keep explicit vectors")
+	serve := flag.String("serve", "", "Serve a live dashboard at this address, e.g. :8080, and pace the run for it (default off)")
+	speed := flag.Float64("speed", 0.01, "-serve: virtual time per wall-clock time, e.g. 0.01 plays 10ms of simulation per second")
+	wire := flag.Bool("wire", false, "Serialize RLNC symbols to the binary wire format on every hop; byte metrics count whole packets")
+	delay := flag.Duration("delay", time.Millisecond, "Per-link propagation delay (virtual time)")
+	jitterSpec := flag.String("jitter", "", "Per-link jitter: uniform:MAX, normal:STDDEV or exp:MEAN, e.g. exp:2ms (default none)")
+	bandwidthSpec := flag.String("bandwidth", "", "Per-link bandwidth, e.g. 10Mbps; adds serialization delay for payload plus coefficient header (default unlimited)")
+	numPeers := flag.Int("peers", 4, "Number of peers")
+	fanout := flag.Int("fanout", 2, "Fanout for random, degree for regular, edges per new peer for ba")
+	topology := flag.String("topology", "random", "Topology: random, regular, er, ba, ring, line, grid, full or star")
+	edgeProb := flag.Float64("edgeprob", 0.5, "Edge probability for the er (Erdős–Rényi) topology")
+	topoFile := flag.String("topofile", "", "Load the topology from an edge-list, JSON adjacency (.json) or GraphML (.graphml) file instead of generating it")
+	seed := flag.Int64("seed", 0, "Random seed for reproducible runs (0 = derive from the current time)")
+	poly := flag.Int("poly", 0, "Primitive polynomial for the Galois Field, e.g. 0x11d or 0x11b (0 = default for -field)")
+	genSize := flag.Int("k", defaultK, "Generation size: source symbols per generation")
+	symSize := flag.Int("symsize", defaultSize, "Symbol payload size in bytes")
+	inputPath := flag.String("input", "", "RLNC source file, split into generations of -k symbols (- for stdin; default one generation of random data)")
 	flag.Parse()
 
-	if *fieldBits != 8 && *fieldBits != 16 {
-		fmt.Println("Error: field size must be either 8 or 16 bits")
+	c, err := NewCoding(*fieldBits, *poly, *genSize, *symSize)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if (*compare || *multihop || *codeType == "rs") && c.rsShards() > 256 {
+		fmt.Printf("Error: Reed-Solomon supports at most 256 shards, so -k must be at most 128\n")
+		return
+	}
+	if *hops <= 0 {
+		fmt.Println("Error: -hops must be at least 1")
+		return
+	}
+	if *fanout < 1 || !(*edgeProb >= 0 && *edgeProb <= 1) {
+		fmt.Println("Error: -fanout must be at least 1 and -edgeprob in [0, 1]")
+		return
+	}
+	if *topoFile == "" && *topology == "regular" && *fanout >= *numPeers {
+		fmt.Printf("Error: a regular topology needs -fanout below -peers (%d)\n", *numPeers)
+		return
+	}
+	if *serve != "" && (*multihop || *speed <= 0) {
+		fmt.Println("Error: -serve needs a positive -speed and streams gossip runs only, not -multihop")
+		return
+	}
+	if *wire && (c.K > 0xffff || c.Size > 0xffff) {
+		fmt.Println("Error: the wire format holds -k and -symsize up to 65535")
+		return
+	}
+	var input []byte
+	if *inputPath != "" {
+		if *compare || *multihop || *codeType != "rlnc" {
+			fmt.Println("Error: -input is only supported for the RLNC gossip run")
+			return
+		}
+		if input, err = readInput(*inputPath); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+
+	if *density <= 0 || *density > 1 || *degree < 0 || *degree > c.K {
+		fmt.Printf("Error: -density must be in (0, 1] and -degree in [0, %d]\n", c.K)
 		return
 	}
+	sparse := sparsity{density: *density, degree: *degree}
+	if *seedCoeff && !sparse.dense() {
+		fmt.Println("Error: -seedcoeff regenerates dense vectors only; drop -density/-degree")
+		return
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
 
-	rand.Seed(time.Now().UnixNano())
+	newChannel, err := parseChannel(*channelSpec, *lossProb)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	channelDesc := fmt.Sprintf("%s (mean loss %.3f)", channelName(*channelSpec), newChannel().MeanLoss())
 
 	if *multihop {
-		fmt.Printf("Multi-hop simulation: %d hops, loss per hop: %.2f\n", *hops, *lossProb)
-		innovRLNC := simulateMultihopRLNC(*lossProb, *fieldBits, *hops)
-		innovRS := simulateMultihopRS(*lossProb, *hops)
-		fmt.Printf("RLNC innovative at destination: %d/%d\n", innovRLNC, k)
-		fmt.Printf("RS innovative at destination:   %d/%d\n", innovRS, k)
+		fmt.Printf("Multi-hop simulation: %d hops, loss per hop: %s, seed: %d\n", *hops, channelDesc, *seed)
+		rankRLNC, okRLNC, hopsRLNC, opsRLNC := simulateMultihopRLNC(newChannel, c, *hops, *systematic, rng)
+		uniqueRS, hopsRS := simulateMultihopRS(newChannel, c, *hops, rng)
+		fmt.Printf("Packets per hop (sent %d): RLNC %v  RS %v\n", 2*c.K, hopsRLNC, hopsRS)
+		fmt.Printf("RLNC rank at destination: %d/%d  decoded: %v  decode cost: %d row ops\n", rankRLNC, c.K, okRLNC, opsRLNC)
+		fmt.Printf("RS unique shards at destination: %d/%d  decodable: %v\n", uniqueRS, 2*c.K, uniqueRS >= c.K)
+		if *systematic {
+			// Same chain without systematic symbols, for the cost comparison
+			_, _, _, opsDense := simulateMultihopRLNC(newChannel, c, *hops, false, rand.New(rand.NewSource(*seed)))
+			fmt.Printf("RLNC systematic vs non-systematic decode cost: %d vs %d row ops (%s)\n",
+				opsRLNC, opsDense, savings(float64(opsRLNC), float64(opsDense)))
+		}
 		return
 	}
 
+	var graph *Graph
+	if *topoFile != "" {
+		graph, err = loadTopology(*topoFile)
+		*topology = *topoFile
+	} else {
+		graph, err = buildTopology(*topology, *numPeers, *fanout, *edgeProb, rng)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	jitter, err := parseJitter(*jitterSpec)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	bandwidth, err := parseBandwidth(*bandwidthSpec)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	sp := simParams{graph: graph, channel: newChannel, delay: *delay, jitter: jitter, bandwidth: bandwidth,
+		recode: *recode, systematic: *systematic, sparsity: sparse, seeded: *seedCoeff, input: input, wire: *wire}
+	if *serve != "" {
+		if sp.dash, err = NewDashboard(*serve, *speed); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Printf("Dashboard at %s; waiting for a browser to connect...\n", sp.dash.URL())
+		sp.dash.WaitClient()
+		defer sp.dash.Hold()
+	}
+
 	fmt.Printf("Running simulation with:\n")
-	fmt.Printf("  - Packet loss probability: %.2f\n", *lossProb)
-	fmt.Printf("  - Galois Field size: GF(2^%d)\n", *fieldBits)
+	fmt.Printf("  - Seed: %d\n", *seed)
+	fmt.Printf("  - Topology: %s, %d peers, %d directed links\n", *topology, graph.n, graph.Arcs())
+	if unreachable := graph.Unreachable(0); len(unreachable) > 0 {
+		names := make([]string, len(unreachable))
+		for i, u := range unreachable {
+			names[i] = graph.name(u)
+		}
+		fmt.Printf("  - Warning: %d peers unreachable from source %s: %s\n", len(names), graph.name(0), strings.Join(names, ", "))
+	}
+	fmt.Printf("  - Channel: %s\n", channelDesc)
+	if *jitterSpec == "" {
+		*jitterSpec = "none"
+	}
+	fmt.Printf("  - Links: delay %v, jitter %s, bandwidth %s\n", *delay, *jitterSpec, formatBandwidth(bandwidth))
+	if input != nil {
+		gens := c.generationCount(len(input))
+		fmt.Printf("  - Input: %s, %d B in %d generations of k=%d (last padded by %d B)\n",
+			*inputPath, len(input), gens, c.K, gens*c.K*c.Size-len(input))
+	}
+	if *seedCoeff {
+		fmt.Printf("  - Symbol size: %d B payload + %d B seed header (recoded and systematic symbols: %d B vector)\n",
+			c.Size, seedHeaderBytes, c.coeffBytes())
+	} else {
+		fmt.Printf("  - Symbol size: %d B payload + %d B coefficient header\n", c.Size, c.coeffBytes())
+	}
+	if *wire {
+		fmt.Printf("  - Wire format: v%d packets, %d B framing and CRC32C per symbol\n", wireVersion, wireHeaderSize+wireCRCSize)
+	}
+	fmt.Printf("  - Galois Field size: GF(2^%d), polynomial %#x\n", c.bits, c.poly)
+	if *recode {
+		fmt.Printf("  - Relay mode: recode\n")
+	} else {
+		fmt.Printf("  - Relay mode: forward-only\n")
+	}
+	if *systematic {
+		fmt.Printf("  - RLNC source: systematic (k uncoded chunks, then repair symbols)\n")
+	}
+	if !sparse.dense() {
+		fmt.Printf("  - RLNC coefficients: sparse, %v\n", sparse)
+	}
 
 	if *compare {
-		// Run RLNC, RS, and plain and print a markdown table
-		innovR, dupR, latR := simulate(false, *lossProb, *fieldBits)
+		// Run every scheme and print a markdown table
+		innovR, dupR, latR, decR := simulate(schemeRLNC, sp, c, rng)
 		p50R, p95R := computeLatencyStats(latR)
-		innovS, dupS, latS := simulateRS(*lossProb)
+		innovS, dupS, latS, decS := simulate(schemeRS, sp, c, rng)
 		p50S, p95S := computeLatencyStats(latS)
-		innovP, _, latP := simulate(true, *lossProb, *fieldBits)
+		innovP, _, latP, decP := simulate(schemePlain, sp, c, rng)
 		p50P, p95P := computeLatencyStats(latP)
-		fmt.Println("\n| Scheme | Avg Innovative | Avg Dups | Latency p50 | Latency p95 |")
-		fmt.Println("|--------|----------------|----------|-------------|-------------|")
-		fmt.Printf("| RLNC   | %.1f           | %.1f     | %v   | %v   |\n", innovR, dupR, p50R, p95R)
-		fmt.Printf("| RS     | %.1f           | %.1f     | %v   | %v   |\n", innovS, dupS, p50S, p95S)
-		fmt.Printf("| Plain  | %.1f           |    -     | %v   | %v   |\n", innovP, p50P, p95P)
+		innovL, dupL, latL, decL := simulate(schemeLT, sp, c, rng)
+		p50L, p95L := computeLatencyStats(latL)
+		innovQ, dupQ, latQ, decQ := simulate(schemeRaptor, sp, c, rng)
+		p50Q, p95Q := computeLatencyStats(latQ)
+		fmt.Println("\n| Scheme | Avg Innovative | Avg Dups | Latency p50 | Latency p95 | Decoded |")
+		fmt.Println("|--------|----------------|----------|-------------|-------------|---------|")
+		fmt.Printf("| RLNC   | %.1f           | %.1f     | %v   | %v   | %d/%d     |\n", innovR, dupR, p50R, p95R, countDecoded(decR), graph.n-1)
+		fmt.Printf("| RS     | %.1f           | %.1f     | %v   | %v   | %d/%d     |\n", innovS, dupS, p50S, p95S, countDecoded(decS), graph.n-1)
+		fmt.Printf("| Plain  | %.1f           |    -     | %v   | %v   | %d/%d     |\n", innovP, p50P, p95P, countDecoded(decP), graph.n-1)
+		fmt.Printf("| LT     | %.1f           | %.1f     | %v   | %v   | %d/%d     |\n", innovL, dupL, p50L, p95L, countDecoded(decL), graph.n-1)
+		fmt.Printf("| Raptor | %.1f           | %.1f     | %v   | %v   | %d/%d     |\n", innovQ, dupQ, p50Q, p95Q, countDecoded(decQ), graph.n-1)
+		printCompletionTable([]string{"RLNC", "RS", "Plain", "LT", "Raptor"},
+			[]completion{collectCompletion(decR), collectCompletion(decS), collectCompletion(decP),
+				collectCompletion(decL), collectCompletion(decQ)})
 		return
 	}
 
 	fmt.Printf("  - Coding scheme: %s\n", *codeType)
 
 	if *codeType == "rlnc" {
-		innov, dup, latencies := simulate(false, *lossProb, *fieldBits)
+		innov, dup, latencies, decodes := simulate(schemeRLNC, sp, c, rng)
 		p50, p95 := computeLatencyStats(latencies)
-		fmt.Printf("RLNC   avg innovative symbols: %.1f  avg dups: %.1f\n", innov, dup)
+		dep, rankDep := avgDependent(decodes)
+		fmt.Printf("RLNC   avg innovative symbols: %.1f  avg dups: %.1f  avg dependent: %.1f\n", innov, dup, dep)
+		total := innov + dup + dep
+		fmt.Printf("       non-innovative rate: %.1f%% (duplicates %.1f%%, dependent %.1f%%)\n",
+			100*(dup+dep)/total, 100*dup/total, 100*dep/total)
+		fmt.Printf("       dependent before full rank: %.1f per peer\n", rankDep)
 		fmt.Printf("       latency p50: %v  p95: %v\n", p50, p95)
+		r50, r95 := computeLatencyStats(rankLatencies(decodes))
+		fmt.Printf("       time to full rank p50: %v  p95: %v\n", r50, r95)
+		fmt.Printf("       decoded: %d/%d receivers\n", countDecoded(decodes), graph.n-1)
+		ops, dur := avgDecodeCost(decodes)
+		fmt.Printf("       decode cost per peer: %.0f row ops, %v\n", ops, dur)
+		if *systematic {
+			// Same run without systematic symbols, for the cost comparison
+			dense := sp
+			dense.systematic, dense.dash = false, nil
+			_, _, _, base := simulate(schemeRLNC, dense, c, rand.New(rand.NewSource(*seed)))
+			baseOps, baseDur := avgDecodeCost(base)
+			fmt.Printf("       non-systematic:       %.0f row ops, %v (systematic: %s)\n", baseOps, baseDur, savings(ops, baseOps))
+		}
+		header, total := avgHeaderBytes(decodes)
+		fmt.Printf("       header bytes per peer: %.0f of %.0f received (%.1f%%)\n", header, total, 100*header/total)
+		if *seedCoeff {
+			// Same run with explicit vectors, for the overhead comparison
+			explicit := sp
+			explicit.seeded, explicit.dash = false, nil
+			_, _, _, base := simulate(schemeRLNC, explicit, c, rand.New(rand.NewSource(*seed)))
+			baseHeader, baseTotal := avgHeaderBytes(base)
+			fmt.Printf("       explicit vectors:      %.0f of %.0f received (seeds: %s header bytes)\n",
+				baseHeader, baseTotal, savings(header, baseHeader))
+		}
+		printDecodes(decodes)
+		if c.generationCount(len(input)) > 1 {
+			printGenerations(decodes, c.K)
+		}
+		printCompletionTable([]string{"RLNC"}, []completion{collectCompletion(decodes)})
+		if !sparse.dense() {
+			// Point-to-point sweep around the chosen setting, on its own
+			// source so the run above stays reproducible from the seed
+			settings := []sparsity{{density: 1}, {density: 0.5}, {density: 0.25}, {density: 0.1}, {density: 0.05}}
+			if !slices.Contains(settings, sparse) {
+				settings = append(settings, sparse)
+			}
+			fmt.Printf("\nSparse RLNC tradeoff (lossless point-to-point, %d trials each):", sparseTrials)
+			printDensityTradeoff(densityTradeoff(c, settings, sparseTrials, rand.New(rand.NewSource(*seed))))
+		}
 	} else if *codeType == "rs" {
-		innov, dup, latencies := simulateRS(*lossProb)
+		innov, dup, latencies, decodes := simulate(schemeRS, sp, c, rng)
 		p50, p95 := computeLatencyStats(latencies)
 		fmt.Printf("RS     avg innovative symbols: %.1f  avg dups: %.1f\n", innov, dup)
 		fmt.Printf("       latency p50: %v  p95: %v\n", p50, p95)
+		fmt.Printf("       decoded: %d/%d receivers\n", countDecoded(decodes), graph.n-1)
+		printDecodes(decodes)
+		printCompletionTable([]string{"RS"}, []completion{collectCompletion(decodes)})
 	} else if *codeType == "plain" {
-		innov, _, latencies := simulate(true, *lossProb, *fieldBits)
+		innov, _, latencies, decodes := simulate(schemePlain, sp, c, rng)
 		p50, p95 := computeLatencyStats(latencies)
 		fmt.Printf("Plain  avg chunks received   : %.1f  (duplicates not tracked)\n", innov)
 		fmt.Printf("       latency p50: %v  p95: %v\n", p50, p95)
+		fmt.Printf("       complete: %d/%d receivers\n", countDecoded(decodes), graph.n-1)
+		printCompletionTable([]string{"Plain"}, []completion{collectCompletion(decodes)})
+	} else if *codeType == "lt" || *codeType == "raptor" {
+		code, name := schemeLT, "LT"
+		if *codeType == "raptor" {
+			code, name = schemeRaptor, "Raptor"
+		}
+		innov, dup, latencies, decodes := simulate(code, sp, c, rng)
+		p50, p95 := computeLatencyStats(latencies)
+		fmt.Printf("%-6s avg useful symbols: %.1f  avg redundant: %.1f\n", name, innov, dup)
+		fmt.Printf("       latency p50: %v  p95: %v\n", p50, p95)
+		fmt.Printf("       decoded: %d/%d receivers\n", countDecoded(decodes), graph.n-1)
+		printDecodes(decodes)
+		printCompletionTable([]string{name}, []completion{collectCompletion(decodes)})
 	} else {
-		fmt.Println("Unknown code type. Use 'rlnc', 'rs', or 'plain'.")
+		fmt.Println("Unknown code type. Use 'rlnc', 'rs', 'plain', 'lt' or 'raptor'.")
 	}
 }
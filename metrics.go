@@ -0,0 +1,159 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// percentiles returns the p50, p95, p99 and maximum of v, sorting v in
+// place. All four are zero for an empty slice.
+func percentiles[T cmp.Ordered](v []T) (p50, p95, p99, hi T) {
+	if len(v) == 0 {
+		return
+	}
+	slices.Sort(v)
+	at := func(p int) T { return v[len(v)*p/100] }
+	return at(50), at(95), at(99), v[len(v)-1]
+}
+
+// completion holds the per-peer completion metrics of one run, each over
+// the peers it applies to: times and symbol counts over the peers that
+// got there, bytes over every peer. rank and decode are in the run's own
+// clock, virtual for the simulator; cpu is the wall-clock decode time,
+// kept apart so it never leaks into a reproducible number.
+type completion struct {
+	rank, decode []time.Duration
+	cpu          []time.Duration
+	symbols      []int
+	bytes        []int
+	n            int // peers in the run
+}
+
+func collectCompletion(decodes []decodeResult) completion {
+	c := completion{n: len(decodes)}
+	for _, d := range decodes {
+		if d.timeToRank >= 0 {
+			c.rank = append(c.rank, d.timeToRank)
+			c.symbols = append(c.symbols, d.symbols)
+		}
+		if d.timeToDecode >= 0 {
+			c.decode = append(c.decode, d.timeToDecode)
+			c.cpu = append(c.cpu, d.duration)
+		}
+		c.bytes = append(c.bytes, d.bytes)
+	}
+	return c
+}
+
+// printCompletionTable prints p50/p95/p99/max of every completion metric
+// for each scheme as a markdown table. Peers counts the peers a row is
+// taken over.
+func printCompletionTable(names []string, runs []completion) {
+	fmt.Println("\n| Scheme | Metric            | Peers     | p50         | p95         | p99         | max         |")
+	fmt.Println("|--------|-------------------|-----------|-------------|-------------|-------------|-------------|")
+	for i, c := range runs {
+		row := func(metric string, peers int, p50, p95, p99, hi any) {
+			if peers == 0 {
+				p50, p95, p99, hi = "-", "-", "-", "-"
+			}
+			fmt.Printf("| %-6s | %-17s | %-9s | %-11v | %-11v | %-11v | %-11v |\n",
+				names[i], metric, fmt.Sprintf("%d/%d", peers, c.n), p50, p95, p99, hi)
+		}
+		a, b, d, e := percentiles(c.rank)
+		row("time to full rank", len(c.rank), a, b, d, e)
+		a, b, d, e = percentiles(c.decode)
+		row("time to decode", len(c.decode), a, b, d, e)
+		a, b, d, e = percentiles(c.cpu)
+		row("decode CPU time", len(c.cpu), a, b, d, e)
+		x, y, z, w := percentiles(c.symbols)
+		row("symbols at decode", len(c.symbols), x, y, z, w)
+		x, y, z, w = percentiles(c.bytes)
+		row("bytes received", len(c.bytes), x, y, z, w)
+	}
+}
+
+// avgDecodeCost returns the mean payload row operations and wall-clock
+// decode time over the peers that decoded.
+func avgDecodeCost(decodes []decodeResult) (ops float64, dur time.Duration) {
+	n := 0
+	for _, d := range decodes {
+		if d.ok {
+			ops += float64(d.ops)
+			dur += d.duration
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return ops / float64(n), dur / time.Duration(n)
+}
+
+// printGenerations reports per-generation rank: how many generations each
+// peer brought to full rank and, if not all, its least complete one.
+// Large meshes only get the summary line.
+func printGenerations(decodes []decodeResult, k int) {
+	all := 0
+	for _, d := range decodes {
+		done, low, lowGen := 0, k, 0
+		for g, r := range d.genRanks {
+			if r == k {
+				done++
+			} else if r < low {
+				low, lowGen = r, g
+			}
+		}
+		if done == len(d.genRanks) {
+			all++
+		}
+		if len(decodes) > 16 {
+			continue
+		}
+		if done == len(d.genRanks) {
+			fmt.Printf("       peer %d: %d/%d generations at full rank\n", d.peer, done, len(d.genRanks))
+		} else {
+			fmt.Printf("       peer %d: %d/%d generations at full rank, lowest rank %d/%d (generation %d)\n",
+				d.peer, done, len(d.genRanks), low, k, lowGen)
+		}
+	}
+	fmt.Printf("       every generation at full rank: %d/%d peers\n", all, len(decodes))
+}
+
+// avgHeaderBytes returns the mean coefficient header bytes and total bytes
+// received per peer.
+func avgHeaderBytes(decodes []decodeResult) (header, total float64) {
+	if len(decodes) == 0 {
+		return 0, 0
+	}
+	for _, d := range decodes {
+		header += float64(d.headerBytes)
+		total += float64(d.bytes)
+	}
+	n := float64(len(decodes))
+	return header / n, total / n
+}
+
+// avgDependent returns the mean number of distinct but linearly
+// dependent symbols per peer, in all and before full rank; for a peer
+// that never got to full rank the two are the same.
+func avgDependent(decodes []decodeResult) (all, beforeRank float64) {
+	if len(decodes) == 0 {
+		return 0, 0
+	}
+	for _, d := range decodes {
+		all += float64(d.dependent)
+		beforeRank += float64(d.rankDep)
+	}
+	n := float64(len(decodes))
+	return all / n, beforeRank / n
+}
+
+// savings formats how much smaller cost is than base, in percent.
+func savings(cost, base float64) string {
+	if base == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.0f%% fewer", 100*(base-cost)/base)
+}
@@ -0,0 +1,88 @@
+package main
+
+import "math/rand"
+
+// Seed-compressed coefficients: instead of k field elements, a source
+// symbol's header carries a 32-bit seed, and the receiver regenerates the
+// coefficient vector from it with the same generator. Only symbols mixed
+// straight from the source chunks can be sent this way; a recoded symbol's
+// coefficients are a combination of other vectors, not the output of a
+// seed, so it falls back to the explicit vector.
+
+// seedHeaderBytes is the size of a seed-compressed coefficient header.
+const seedHeaderBytes = 4
+
+// splitmix64 is the coefficient generator behind seeded symbols. It is
+// spelled out here rather than taken from math/rand so the sequence is
+// fixed by this file alone and a peer in any language can reproduce it.
+type splitmix64 uint64
+
+func (s *splitmix64) next() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+// seedCoeffs regenerates the coefficient vector of a seeded symbol: k
+// uniform field elements, or k bits in GF(2). An all-zero draw is
+// replaced by setting the coefficient the next output selects to 1, as
+// mixSymbol does, so seeded symbols are never empty.
+func seedCoeffs(seed uint32, c *Coding) (coeff []uint16, bits []uint64) {
+	g, k := splitmix64(seed), c.K
+	nonZero := false
+	if c.bits == 1 {
+		bits = make([]uint64, packedWords(k))
+		for w := range bits {
+			bits[w] = g.next()
+		}
+		if k%64 != 0 {
+			bits[len(bits)-1] &= 1<<(k%64) - 1
+		}
+		for _, w := range bits {
+			nonZero = nonZero || w != 0
+		}
+		if !nonZero {
+			i := int(g.next() % uint64(k))
+			bits[i/64] |= 1 << (i % 64)
+		}
+		return nil, bits
+	}
+	coeff = make([]uint16, k)
+	for i := range coeff {
+		coeff[i] = uint16(g.next()) & uint16(c.size-1)
+		nonZero = nonZero || coeff[i] != 0
+	}
+	if !nonZero {
+		coeff[g.next()%uint64(k)] = 1
+	}
+	return coeff, nil
+}
+
+// mixSeeded returns a random combination of src[0:k] whose coefficients
+// come from a fresh seed drawn from rng. The symbol is returned compact,
+// with only the seed set; receivers call expand before using it.
+func mixSeeded(src []Symbol, c *Coding, rng *rand.Rand) Symbol {
+	seed := rng.Uint32()
+	coeff, bits := seedCoeffs(seed, c)
+	data := make([]byte, c.Size)
+	for i := 0; i < c.K; i++ {
+		if bits != nil {
+			if bitAt(bits, i) != 0 {
+				xorBytes(data, src[i].Data)
+			}
+		} else {
+			c.MulAdd(data, src[i].Data, coeff[i])
+		}
+	}
+	return Symbol{Seed: seed, Seeded: true, Data: data}
+}
+
+// expand regenerates the coefficients of a compact seeded symbol in place.
+// Symbols that already carry a vector are left alone.
+func (s *Symbol) expand(c *Coding) {
+	if s.Seeded && s.Coeff == nil && s.Bits == nil {
+		s.Coeff, s.Bits = seedCoeffs(s.Seed, c)
+	}
+}
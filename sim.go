@@ -0,0 +1,83 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Sim is a discrete-event simulator with a virtual clock. Events run in
+// timestamp order (ties in scheduling order), so a run takes only as long
+// as the CPU needs and its outcome does not depend on the Go scheduler.
+type Sim struct {
+	now    time.Duration
+	seq    uint64
+	queue  eventQueue
+	events int     // number of events processed
+	speed  float64 // virtual time per wall-clock time when paced, 0 for unpaced
+}
+
+type event struct {
+	at  time.Duration
+	seq uint64
+	fn  func()
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+func NewSim() *Sim {
+	return &Sim{}
+}
+
+// Now returns the current virtual time.
+func (s *Sim) Now() time.Duration {
+	return s.now
+}
+
+// Schedule runs fn after delay units of virtual time.
+func (s *Sim) Schedule(delay time.Duration, fn func()) {
+	s.seq++
+	heap.Push(&s.queue, &event{at: s.now + delay, seq: s.seq, fn: fn})
+}
+
+// Pace makes Run hold each event back until its virtual time, divided
+// by speed, has passed on the wall clock, so a viewer can follow the run.
+// 0.01 plays 10ms of virtual time per second.
+func (s *Sim) Pace(speed float64) {
+	s.speed = speed
+}
+
+// Run processes events until the queue is empty or done reports true,
+// checked after every event. It returns the virtual time at which the
+// run ended.
+func (s *Sim) Run(done func() bool) time.Duration {
+	start := time.Now()
+	for s.queue.Len() > 0 {
+		e := heap.Pop(&s.queue).(*event)
+		if s.speed > 0 {
+			time.Sleep(time.Until(start.Add(time.Duration(float64(e.at) / s.speed))))
+		}
+		s.now = e.at
+		e.fn()
+		s.events++
+		if done != nil && done() {
+			break
+		}
+	}
+	return s.now
+}
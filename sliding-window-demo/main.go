@@ -1,11 +1,12 @@
 package main
 
 import (
-	crand "crypto/rand"
 	"flag"
 	"fmt"
 	"math/rand"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -22,6 +23,7 @@ type Packet struct {
 	ID        int
 	Data      []byte
 	Coeffs    []byte // For coded packets
+	Start     int    // ID of the first packet covered by Coeffs
 	IsCoded   bool
 	Timestamp time.Time
 }
@@ -78,27 +80,195 @@ func (sw *SlidingWindow) SlideWindow(ackCount int) {
 	}
 }
 
-// GF represents Galois Field for coding
+// GF represents Galois Field GF(2^8) for coding, using log/antilog
+// tables built from a primitive polynomial
 type GF struct {
-	mulTable [][]byte
+	poly int
+	exp  []int // exp[i] = gen^i, doubled so log sums need no modulo
+	log  []int
 }
 
-func NewGF() *GF {
+func NewGF(poly int) (*GF, error) {
+	if poly>>8 != 1 {
+		return nil, fmt.Errorf("polynomial %#x is not of degree 8", poly)
+	}
 	gf := &GF{
-		mulTable: make([][]byte, fieldSize),
+		poly: poly,
+		exp:  make([]int, 2*(fieldSize-1)),
+		log:  make([]int, fieldSize),
+	}
+	// Search for a generator so irreducible non-primitive moduli
+	// (e.g. the AES polynomial 0x11b) work as well
+	for g := 2; g < fieldSize; g++ {
+		if gf.fill(g) {
+			return gf, nil
+		}
 	}
+	return nil, fmt.Errorf("polynomial %#x is not irreducible over GF(2)", poly)
+}
 
-	for i := 0; i < fieldSize; i++ {
-		gf.mulTable[i] = make([]byte, fieldSize)
-		for j := 0; j < fieldSize; j++ {
-			gf.mulTable[i][j] = byte((i * j) % fieldSize)
+func (gf *GF) fill(g int) bool {
+	x := 1
+	for i := 0; i < fieldSize-1; i++ {
+		if i > 0 && x == 1 {
+			return false
 		}
+		gf.exp[i] = x
+		gf.exp[i+fieldSize-1] = x
+		gf.log[x] = i
+		x = gf.slowMul(x, g)
 	}
-	return gf
+	return x == 1
+}
+
+func (gf *GF) slowMul(a, b int) int {
+	r := 0
+	for b > 0 {
+		if b&1 != 0 {
+			r ^= a
+		}
+		b >>= 1
+		a <<= 1
+		if a&fieldSize != 0 {
+			a ^= gf.poly
+		}
+	}
+	return r
 }
 
 func (gf *GF) Mul(a, b byte) byte {
-	return gf.mulTable[a][b]
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return byte(gf.exp[gf.log[a]+gf.log[b]])
+}
+
+func (gf *GF) Div(a, b byte) byte {
+	if b == 0 {
+		panic("gf: division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return byte(gf.exp[gf.log[a]+fieldSize-1-gf.log[b]])
+}
+
+func (gf *GF) Inv(a byte) byte {
+	return gf.Div(1, a)
+}
+
+// MulAdd computes dst ^= c*src
+func (gf *GF) MulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	for i := range src {
+		dst[i] ^= gf.Mul(src[i], c)
+	}
+}
+
+// Scale computes buf *= c in place
+func (gf *GF) Scale(buf []byte, c byte) {
+	for i := range buf {
+		buf[i] = gf.Mul(buf[i], c)
+	}
+}
+
+// ProgressiveDecoder keeps received coefficient vectors in reduced
+// row-echelon form so each packet is inserted in O(k^2) and packets are
+// released as soon as their pivot row becomes a unit vector
+type ProgressiveDecoder struct {
+	gf    *GF
+	k     int
+	coeff [][]byte // coeff[c] is the row with pivot column c, nil if none
+	data  [][]byte
+	rank  int
+}
+
+func NewProgressiveDecoder(gf *GF, k int) *ProgressiveDecoder {
+	return &ProgressiveDecoder{
+		gf:    gf,
+		k:     k,
+		coeff: make([][]byte, k),
+		data:  make([][]byte, k),
+	}
+}
+
+// Add inserts a coefficient vector (length k) with its payload and
+// reports whether it increased the rank
+func (d *ProgressiveDecoder) Add(coeffs, payload []byte) bool {
+	gf := d.gf
+	row := append([]byte(nil), coeffs...)
+	data := append([]byte(nil), payload...)
+
+	for c := 0; c < d.k; c++ {
+		if row[c] == 0 || d.coeff[c] == nil {
+			continue
+		}
+		f := row[c]
+		for j := c; j < d.k; j++ {
+			row[j] ^= gf.Mul(f, d.coeff[c][j])
+		}
+		gf.MulAdd(data, d.data[c], f)
+	}
+
+	piv := -1
+	for c := 0; c < d.k; c++ {
+		if row[c] != 0 {
+			piv = c
+			break
+		}
+	}
+	if piv < 0 {
+		return false
+	}
+
+	inv := gf.Inv(row[piv])
+	for j := piv; j < d.k; j++ {
+		row[j] = gf.Mul(row[j], inv)
+	}
+	gf.Scale(data, inv)
+
+	for c := 0; c < d.k; c++ {
+		if d.coeff[c] == nil || d.coeff[c][piv] == 0 {
+			continue
+		}
+		f := d.coeff[c][piv]
+		for j := piv; j < d.k; j++ {
+			d.coeff[c][j] ^= gf.Mul(f, row[j])
+		}
+		gf.MulAdd(d.data[c], data, f)
+	}
+	d.coeff[piv] = row
+	d.data[piv] = data
+	d.rank++
+	return true
+}
+
+func (d *ProgressiveDecoder) Rank() int {
+	return d.rank
+}
+
+// IsDecoded reports whether packet i is recovered
+func (d *ProgressiveDecoder) IsDecoded(i int) bool {
+	row := d.coeff[i]
+	if row == nil {
+		return false
+	}
+	for j := i + 1; j < d.k; j++ {
+		if row[j] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Chunk returns packet i's payload, or nil if it is not decoded yet
+func (d *ProgressiveDecoder) Chunk(i int) []byte {
+	if !d.IsDecoded(i) {
+		return nil
+	}
+	return d.data[i]
 }
 
 // Sender represents the sliding window RLNC sender
@@ -107,12 +277,14 @@ type Sender struct {
 	gf         *GF
 	codingRate float64 // Ratio of coded packets to data packets
 	packetID   int
+	rng        *rand.Rand
 }
 
-func NewSender(windowSize int, codingRate float64) *Sender {
+func NewSender(windowSize int, codingRate float64, gf *GF, rng *rand.Rand) *Sender {
 	return &Sender{
 		window:     NewSlidingWindow(windowSize),
-		gf:         NewGF(),
+		gf:         gf,
+		rng:        rng,
 		codingRate: codingRate,
 		packetID:   0,
 	}
@@ -120,7 +292,7 @@ func NewSender(windowSize int, codingRate float64) *Sender {
 
 func (s *Sender) CreateDataPacket() *Packet {
 	data := make([]byte, chunkSize)
-	crand.Read(data)
+	s.rng.Read(data)
 
 	pkt := &Packet{
 		ID:        s.packetID,
@@ -143,7 +315,7 @@ func (s *Sender) CreateCodedPacket() *Packet {
 	// Generate random coefficients
 	coeffs := make([]byte, len(windowPackets))
 	for i := range coeffs {
-		coeffs[i] = byte(rand.Intn(fieldSize))
+		coeffs[i] = byte(s.rng.Intn(fieldSize))
 	}
 
 	// Create linear combination
@@ -160,6 +332,7 @@ func (s *Sender) CreateCodedPacket() *Packet {
 		ID:        s.packetID,
 		Data:      codedData,
 		Coeffs:    coeffs,
+		Start:     windowPackets[0].ID,
 		IsCoded:   true,
 		Timestamp: time.Now(),
 	}
@@ -169,72 +342,48 @@ func (s *Sender) CreateCodedPacket() *Packet {
 type Receiver struct {
 	window  *SlidingWindow
 	gf      *GF
+	dec     *ProgressiveDecoder
 	decoded map[int]*Packet
 	delays  []time.Duration
 	mu      sync.Mutex
 }
 
-func NewReceiver(windowSize int) *Receiver {
+func NewReceiver(windowSize int, gf *GF) *Receiver {
 	return &Receiver{
 		window:  NewSlidingWindow(windowSize),
-		gf:      NewGF(),
+		gf:      gf,
+		dec:     NewProgressiveDecoder(gf, totalPackets),
 		decoded: make(map[int]*Packet),
 		delays:  make([]time.Duration, 0),
 	}
 }
 
+// ReceivePacket feeds the packet to the progressive decoder and reports
+// whether it was innovative. Every packet that becomes decodable is
+// delivered immediately, data packets included.
 func (r *Receiver) ReceivePacket(pkt *Packet) bool {
 	r.window.AddPacket(pkt)
 
+	coeffs := make([]byte, totalPackets)
 	if pkt.IsCoded {
-		return r.tryDecode()
+		copy(coeffs[pkt.Start:], pkt.Coeffs)
 	} else {
-		// Data packet received directly
-		r.mu.Lock()
-		r.decoded[pkt.ID] = pkt
-		r.delays = append(r.delays, time.Since(pkt.Timestamp))
-		r.mu.Unlock()
-		return true
+		coeffs[pkt.ID] = 1
 	}
-}
-
-func (r *Receiver) tryDecode() bool {
-	windowPackets := r.window.GetWindowPackets()
-	if len(windowPackets) < 2 {
+	if !r.dec.Add(coeffs, pkt.Data) {
 		return false
 	}
 
-	// Try to decode using received packets
-	innovative := r.findInnovativePackets(windowPackets)
-	if len(innovative) >= len(r.decoded) {
-		// We have enough innovative packets to decode
-		for _, pkt := range innovative {
-			if !pkt.IsCoded {
-				r.mu.Lock()
-				r.decoded[pkt.ID] = pkt
-				r.delays = append(r.delays, time.Since(pkt.Timestamp))
-				r.mu.Unlock()
-			}
-		}
-		return true
-	}
-	return false
-}
-
-func (r *Receiver) findInnovativePackets(packets []*Packet) []*Packet {
-	// Simple innovation check - in practice, you'd use matrix rank
-	seen := make(map[string]bool)
-	innovative := make([]*Packet, 0)
-
-	for _, pkt := range packets {
-		key := string(pkt.Data)
-		if !seen[key] {
-			seen[key] = true
-			innovative = append(innovative, pkt)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id := 0; id < totalPackets; id++ {
+		if _, ok := r.decoded[id]; ok || !r.dec.IsDecoded(id) {
+			continue
 		}
+		r.decoded[id] = &Packet{ID: id, Data: r.dec.Chunk(id), Timestamp: pkt.Timestamp}
+		r.delays = append(r.delays, time.Since(pkt.Timestamp))
 	}
-
-	return innovative
+	return true
 }
 
 func (r *Receiver) GetStats() (int, float64) {
@@ -258,25 +407,118 @@ func (r *Receiver) GetStats() (int, float64) {
 	return len(r.decoded), avgDelay
 }
 
+// Channel decides, packet by packet, whether the link loses what is sent
+// over it. Implementations may keep state, e.g. a burst in progress.
+type Channel interface {
+	Drop(rng *rand.Rand) bool
+	// MeanLoss is the long-run fraction of packets lost.
+	MeanLoss() float64
+}
+
+// Bernoulli loses each packet independently with probability P.
+type Bernoulli struct {
+	P float64
+}
+
+func (b *Bernoulli) Drop(rng *rand.Rand) bool {
+	return rng.Float64() < b.P
+}
+
+func (b *Bernoulli) MeanLoss() float64 {
+	return b.P
+}
+
+// GilbertElliott is a two-state Markov loss model: good->bad with
+// probability P and bad->good with probability R before every packet,
+// losing the packet with LossGood or LossBad depending on the state.
+type GilbertElliott struct {
+	P, R              float64
+	LossGood, LossBad float64
+	bad               bool
+}
+
+func (g *GilbertElliott) Drop(rng *rand.Rand) bool {
+	if g.bad {
+		if rng.Float64() < g.R {
+			g.bad = false
+		}
+	} else if rng.Float64() < g.P {
+		g.bad = true
+	}
+	if g.bad {
+		return rng.Float64() < g.LossBad
+	}
+	return rng.Float64() < g.LossGood
+}
+
+func (g *GilbertElliott) MeanLoss() float64 {
+	if g.P+g.R == 0 {
+		return g.LossGood
+	}
+	piBad := g.P / (g.P + g.R)
+	return piBad*g.LossBad + (1-piBad)*g.LossGood
+}
+
+// parseChannel turns a -channel spec into a constructor for fresh
+// channels: "" or "bernoulli" (loss from -loss), "bernoulli:P", "ge:P,R"
+// (lossless good, lossy bad state) or "ge:P,R,LossGood,LossBad".
+func parseChannel(spec string, loss float64) (func() Channel, error) {
+	kind, args, _ := strings.Cut(spec, ":")
+	var vals []float64
+	if args != "" {
+		for _, a := range strings.Split(args, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+			if err != nil || v < 0 || v > 1 {
+				return nil, fmt.Errorf("channel %q: %q is not a probability", spec, a)
+			}
+			vals = append(vals, v)
+		}
+	}
+	switch kind {
+	case "", "bernoulli":
+		if len(vals) > 1 {
+			return nil, fmt.Errorf("channel %q: want bernoulli:P", spec)
+		}
+		if len(vals) == 1 {
+			loss = vals[0]
+		}
+		return func() Channel { return &Bernoulli{P: loss} }, nil
+	case "ge":
+		switch len(vals) {
+		case 2:
+			vals = append(vals, 0, 1)
+		case 4:
+		default:
+			return nil, fmt.Errorf("channel %q: want ge:P,R or ge:P,R,LossGood,LossBad", spec)
+		}
+		return func() Channel {
+			return &GilbertElliott{P: vals[0], R: vals[1], LossGood: vals[2], LossBad: vals[3]}
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown channel model %q (want bernoulli or ge)", kind)
+}
+
 // BlockRLNC represents traditional block-based RLNC for comparison
 type BlockRLNC struct {
 	blockSize int
 	gf        *GF
+	rng       *rand.Rand
 }
 
-func NewBlockRLNC(blockSize int) *BlockRLNC {
+func NewBlockRLNC(blockSize int, gf *GF, rng *rand.Rand) *BlockRLNC {
 	return &BlockRLNC{
 		blockSize: blockSize,
-		gf:        NewGF(),
+		gf:        gf,
+		rng:       rng,
 	}
 }
 
-func (b *BlockRLNC) SimulateBlockTransmission(lossProb float64) (int, float64) {
+func (b *BlockRLNC) SimulateBlockTransmission(ch Channel) (int, float64) {
 	// Simulate block-based transmission
 	packets := make([]*Packet, b.blockSize)
 	for i := 0; i < b.blockSize; i++ {
 		data := make([]byte, chunkSize)
-		crand.Read(data)
+		b.rng.Read(data)
 		packets[i] = &Packet{
 			ID:        i,
 			Data:      data,
@@ -290,7 +532,7 @@ func (b *BlockRLNC) SimulateBlockTransmission(lossProb float64) (int, float64) {
 	for i := 0; i < b.blockSize; i++ {
 		coeffs := make([]byte, b.blockSize)
 		for j := range coeffs {
-			coeffs[j] = byte(rand.Intn(fieldSize))
+			coeffs[j] = byte(b.rng.Intn(fieldSize))
 		}
 
 		codedData := make([]byte, chunkSize)
@@ -317,7 +559,7 @@ func (b *BlockRLNC) SimulateBlockTransmission(lossProb float64) (int, float64) {
 
 	// Send data packets first
 	for _, pkt := range packets {
-		if rand.Float64() >= lossProb {
+		if !ch.Drop(b.rng) {
 			received++
 			delays = append(delays, time.Since(pkt.Timestamp))
 		}
@@ -325,7 +567,7 @@ func (b *BlockRLNC) SimulateBlockTransmission(lossProb float64) (int, float64) {
 
 	// Send coded packets
 	for _, pkt := range codedPackets {
-		if rand.Float64() >= lossProb {
+		if !ch.Drop(b.rng) {
 			received++
 			delays = append(delays, time.Since(pkt.Timestamp))
 		}
@@ -343,22 +585,22 @@ func (b *BlockRLNC) SimulateBlockTransmission(lossProb float64) (int, float64) {
 	return received, avgDelay
 }
 
-func simulateSlidingWindowRLNC(lossProb, codingRate float64) (int, float64) {
-	sender := NewSender(windowSize, codingRate)
-	receiver := NewReceiver(windowSize)
+func simulateSlidingWindowRLNC(ch Channel, codingRate float64, gf *GF, rng *rand.Rand) (int, float64) {
+	sender := NewSender(windowSize, codingRate, gf, rng)
+	receiver := NewReceiver(windowSize, gf)
 
 	// Simulate transmission
 	for i := 0; i < totalPackets; i++ {
 		// Send data packet
 		dataPkt := sender.CreateDataPacket()
-		if rand.Float64() >= lossProb {
+		if !ch.Drop(rng) {
 			receiver.ReceivePacket(dataPkt)
 		}
 
 		// Send coded packet based on coding rate
-		if rand.Float64() < codingRate {
+		if rng.Float64() < codingRate {
 			codedPkt := sender.CreateCodedPacket()
-			if codedPkt != nil && rand.Float64() >= lossProb {
+			if codedPkt != nil && !ch.Drop(rng) {
 				receiver.ReceivePacket(codedPkt)
 			}
 		}
@@ -374,19 +616,42 @@ func simulateSlidingWindowRLNC(lossProb, codingRate float64) (int, float64) {
 
 func main() {
 	lossProb := flag.Float64("loss", 0.1, "Packet loss probability")
+	channelSpec := flag.String("channel", "", "Loss model: bernoulli[:P] or ge:P,R[,LossGood,LossBad] for Gilbert–Elliott bursts (default bernoulli with -loss)")
 	codingRate := flag.Float64("rate", 0.5, "Coding rate (ratio of coded packets)")
 	blockSize := flag.Int("block", 8, "Block size for block-based RLNC")
 	compare := flag.Bool("compare", false, "Compare sliding window vs block-based RLNC")
+	poly := flag.Int("poly", 0x11d, "Primitive polynomial for GF(256), e.g. 0x11d or 0x11b")
+	seed := flag.Int64("seed", 0, "Random seed for reproducible runs (0 = derive from the current time)")
 	flag.Parse()
 
-	rand.Seed(time.Now().UnixNano())
+	gf, err := NewGF(*poly)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+
+	newChannel, err := parseChannel(*channelSpec, *lossProb)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	channelName := *channelSpec
+	if channelName == "" {
+		channelName = "bernoulli"
+	}
 
 	if *compare {
 		// Compare sliding window vs block-based
-		swReceived, swDelay := simulateSlidingWindowRLNC(*lossProb, *codingRate)
-		blockReceived, blockDelay := NewBlockRLNC(*blockSize).SimulateBlockTransmission(*lossProb)
+		swReceived, swDelay := simulateSlidingWindowRLNC(newChannel(), *codingRate, gf, rng)
+		blockReceived, blockDelay := NewBlockRLNC(*blockSize, gf, rng).SimulateBlockTransmission(newChannel())
 
-		fmt.Printf("Sliding Window vs Block-based RLNC (Loss: %.1f%%, Coding Rate: %.1f)\n", *lossProb*100, *codingRate)
+		fmt.Printf("Sliding Window vs Block-based RLNC (Channel: %s, Loss: %.1f%%, Coding Rate: %.1f, Seed: %d)\n",
+			channelName, newChannel().MeanLoss()*100, *codingRate, *seed)
 		fmt.Printf("┌─────────────────┬──────────────────┬─────────────────┐\n")
 		fmt.Printf("│ Scheme          │ Packets Received │ Avg Delay (μs)  │\n")
 		fmt.Printf("├─────────────────┼──────────────────┼─────────────────┤\n")
@@ -403,10 +668,11 @@ func main() {
 		fmt.Printf("• Throughput improvement: %.1f%%\n", throughputImprovement)
 	} else {
 		// Single simulation
-		received, avgDelay := simulateSlidingWindowRLNC(*lossProb, *codingRate)
+		received, avgDelay := simulateSlidingWindowRLNC(newChannel(), *codingRate, gf, rng)
 		successRate := float64(received) / float64(totalPackets) * 100
 
-		fmt.Printf("Sliding Window RLNC Results\n")
+		fmt.Printf("Sliding Window RLNC Results (Channel: %s, Loss: %.1f%%, Seed: %d)\n",
+			channelName, newChannel().MeanLoss()*100, *seed)
 		fmt.Printf("┌─────────────────┬─────────────────┐\n")
 		fmt.Printf("│ Metric          │ Value           │\n")
 		fmt.Printf("├─────────────────┼─────────────────┤\n")
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Sparse RLNC: each coefficient is non-zero with probability density (or
+// exactly degree coefficients are), which cuts encoding work and, with a
+// decoder that only walks non-zeros, decoding work too. The price is a
+// higher chance that a symbol is linearly dependent on what the receiver
+// already holds, and once that rate climbs every extra symbol costs
+// bandwidth.
+
+// sparsity selects how many coefficients an RLNC encoder sets: exactly
+// degree when degree > 0, otherwise each one with probability density.
+// Density 1 is ordinary dense RLNC.
+type sparsity struct {
+	density float64
+	degree  int
+}
+
+func (s sparsity) dense() bool {
+	return s.degree <= 0 && s.density >= 1
+}
+
+func (s sparsity) String() string {
+	if s.degree > 0 {
+		return fmt.Sprintf("degree %d", s.degree)
+	}
+	return fmt.Sprintf("density %.3g", s.density)
+}
+
+// mixSparse returns a sparse random combination of src[0:k] as selected
+// by s. Non-zero coefficients are uniform over the non-zero field
+// elements, and at least one is always set. The dense setting is plain
+// mixSymbol: in GF(2) "every coefficient non-zero" would be the all-ones
+// vector every time.
+func mixSparse(src []Symbol, c *Coding, s sparsity, rng *rand.Rand) Symbol {
+	if s.dense() {
+		return mixSymbol(src, c, rng)
+	}
+	k := c.K
+	var picked []int
+	if s.degree > 0 {
+		picked = rng.Perm(k)[:min(s.degree, k)]
+	} else {
+		for i := 0; i < k; i++ {
+			if rng.Float64() < s.density {
+				picked = append(picked, i)
+			}
+		}
+		if len(picked) == 0 {
+			picked = append(picked, rng.Intn(k))
+		}
+	}
+
+	data := make([]byte, c.Size)
+	if c.bits == 1 {
+		vec := make([]uint64, packedWords(k))
+		for _, i := range picked {
+			vec[i/64] |= 1 << (i % 64)
+			xorBytes(data, src[i].Data)
+		}
+		return Symbol{Bits: vec, Data: data}
+	}
+	coeff := make([]uint16, k)
+	for _, i := range picked {
+		coeff[i] = uint16(1 + rng.Intn(c.size-1))
+		c.MulAdd(data, src[i].Data, coeff[i])
+	}
+	return Symbol{Coeff: coeff, Data: data}
+}
+
+// sparseRow is a pivot row stored as its non-zero entries in increasing
+// column order; the first entry is the pivot with value 1.
+type sparseRow struct {
+	cols []int
+	vals []uint16
+}
+
+// sparseOf returns the non-zero coefficients of sym as a row, reading
+// GF(2) vectors a word at a time.
+func sparseOf(sym *Symbol, k int) sparseRow {
+	var r sparseRow
+	if sym.Bits != nil {
+		for w, word := range sym.Bits {
+			for ; word != 0; word &= word - 1 {
+				r.cols = append(r.cols, 64*w+bits.TrailingZeros64(word))
+				r.vals = append(r.vals, 1)
+			}
+		}
+		return r
+	}
+	for j, v := range sym.Coeff[:k] {
+		if v != 0 {
+			r.cols, r.vals = append(r.cols, j), append(r.vals, v)
+		}
+	}
+	return r
+}
+
+// addScaled sets out to r + f*o, merging the two sorted entry lists and
+// dropping entries that cancel. out's storage is reused.
+func (r *sparseRow) addScaled(o *sparseRow, f uint16, gf *GF, out *sparseRow) {
+	cols, vals := out.cols[:0], out.vals[:0]
+	i, j := 0, 0
+	for i < len(r.cols) || j < len(o.cols) {
+		switch {
+		case j == len(o.cols) || (i < len(r.cols) && r.cols[i] < o.cols[j]):
+			cols, vals = append(cols, r.cols[i]), append(vals, r.vals[i])
+			i++
+		case i == len(r.cols) || o.cols[j] < r.cols[i]:
+			cols, vals = append(cols, o.cols[j]), append(vals, gf.Mul(f, o.vals[j]))
+			j++
+		default:
+			if v := r.vals[i] ^ gf.Mul(f, o.vals[j]); v != 0 {
+				cols, vals = append(cols, r.cols[i]), append(vals, v)
+			}
+			i++
+			j++
+		}
+	}
+	out.cols, out.vals = cols, vals
+}
+
+// at returns the entry of r in column c.
+func (r *sparseRow) at(c int) uint16 {
+	i := sort.SearchInts(r.cols, c)
+	if i < len(r.cols) && r.cols[i] == c {
+		return r.vals[i]
+	}
+	return 0
+}
+
+// sparseFill is the fill-in at which SparseDecoder.Add stops merging
+// entry lists: a row with more than k/sparseFill non-zeros is reduced in a
+// dense scratch vector instead.
+const sparseFill = 8
+
+// SparseDecoder is an online decoder for sparse symbols. Add only
+// decides whether a symbol is innovative: it reduces the coefficients
+// against row-echelon pivot rows stored as entry lists, just until the
+// leading entry lands in a column without a pivot, and touches no
+// payload. Data then solves the k innovative symbols in one pass,
+// pivoting in minimum-degree order so sparse symbols cause little
+// fill-in and therefore few payload row operations. Once the first
+// sparseFill or more symbols average over k/sparseFill non-zeros, fill-in
+// is unavoidable and the decoder hands them to a ProgressiveDecoder, whose
+// RREF is cheaper there.
+type SparseDecoder struct {
+	gf      *GF
+	k       int
+	rows    []*sparseRow        // rows[c] has its pivot in column c, nil if none
+	held    []*Symbol           // the innovative symbols, in arrival order
+	nnz     int                 // non-zero coefficients across held
+	dense   *ProgressiveDecoder // set once the symbols turn out dense
+	rank    int
+	ops     int       // payload row operations, see Decoder.Ops
+	out     []byte    // the decoded chunks, once Data has run
+	spare   sparseRow // merge buffer for Add
+	scratch []uint16  // dense row for Add once fill-in passes k/sparseFill, kept zero
+}
+
+func NewSparseDecoder(gf *GF, k int) *SparseDecoder {
+	return &SparseDecoder{gf: gf, k: k, rows: make([]*sparseRow, k), scratch: make([]uint16, k)}
+}
+
+// Add inserts a symbol and reports whether it increased the rank. The
+// symbol is kept, not copied, until Data.
+func (d *SparseDecoder) Add(sym *Symbol) bool {
+	if d.dense != nil {
+		if !d.dense.Add(sym) {
+			return false
+		}
+		d.rank++
+		return true
+	}
+	gf := d.gf
+	w := sparseOf(sym, d.k)
+	nnz := len(w.cols)
+
+	// Clear leading entries with the pivot rows while the row stays sparse
+	for len(w.cols) > 0 && len(w.cols)*sparseFill <= d.k {
+		r := d.rows[w.cols[0]]
+		if r == nil {
+			break
+		}
+		w.addScaled(r, w.vals[0], gf, &d.spare)
+		w, d.spare = d.spare, w
+	}
+	if len(w.cols) > 0 && d.rows[w.cols[0]] != nil {
+		// Filled in: finish on the dense scratch row, still walking only
+		// the pivot rows' entries
+		row := d.scratch
+		for i, c := range w.cols {
+			row[c] = w.vals[i]
+		}
+		w.cols, w.vals = w.cols[:0], w.vals[:0]
+		for c := 0; c < d.k; c++ {
+			if row[c] == 0 {
+				continue
+			}
+			r := d.rows[c]
+			if r == nil {
+				// New leading entry; collect the rest and clear scratch
+				for j := c; j < d.k; j++ {
+					if row[j] != 0 {
+						w.cols, w.vals = append(w.cols, j), append(w.vals, row[j])
+						row[j] = 0
+					}
+				}
+				break
+			}
+			f := row[c]
+			for i, j := range r.cols {
+				row[j] ^= gf.Mul(f, r.vals[i])
+			}
+		}
+	}
+	if len(w.cols) == 0 {
+		return false
+	}
+
+	nr := &sparseRow{cols: append([]int(nil), w.cols...), vals: make([]uint16, len(w.vals))}
+	inv := gf.Inv(w.vals[0])
+	for i, v := range w.vals {
+		nr.vals[i] = gf.Mul(v, inv)
+	}
+	d.rows[w.cols[0]] = nr
+	d.held = append(d.held, sym)
+	d.nnz += nnz
+	d.rank++
+	if len(d.held) >= sparseFill && d.nnz*sparseFill > d.k*len(d.held) {
+		d.dense = NewProgressiveDecoder(gf, d.k)
+		for _, s := range d.held {
+			d.dense.Add(s)
+		}
+		d.rows, d.held = nil, nil
+	}
+	return true
+}
+
+// solve recovers the source chunks from the k held symbols. Each step
+// pivots the sparsest remaining row on its least shared column and
+// eliminates that column from the other remaining rows; a pivot row's
+// other entries are all in columns pivoted later, so back-substitution in
+// reverse pivot order finishes it.
+func (d *SparseDecoder) solve() {
+	gf, k := d.gf, d.k
+	rows := make([]sparseRow, k)
+	data := make([][]byte, k)
+	colCount := make([]int, k) // remaining rows with an entry in each column
+	for i, s := range d.held {
+		rows[i] = sparseOf(s, k)
+		data[i] = append([]byte(nil), s.Data...)
+		for _, c := range rows[i].cols {
+			colCount[c]++
+		}
+	}
+	done := make([]bool, k)
+	order, pivCol := make([]int, 0, k), make([]int, k)
+	var spare sparseRow
+	for len(order) < k {
+		p := -1
+		for i := range rows {
+			if !done[i] && (p < 0 || len(rows[i].cols) < len(rows[p].cols)) {
+				p = i
+			}
+		}
+		r := &rows[p]
+		pi := 0
+		for i, c := range r.cols {
+			if colCount[c] < colCount[r.cols[pi]] {
+				pi = i
+			}
+		}
+		pc := r.cols[pi]
+		done[p] = true
+		for _, c := range r.cols {
+			colCount[c]--
+		}
+		if inv := gf.Inv(r.vals[pi]); inv != 1 {
+			for i := range r.vals {
+				r.vals[i] = gf.Mul(r.vals[i], inv)
+			}
+			gf.Scale(data[p], inv)
+			d.ops++
+		}
+		for i := range rows {
+			if done[i] {
+				continue
+			}
+			f := rows[i].at(pc)
+			if f == 0 {
+				continue
+			}
+			for _, c := range rows[i].cols {
+				colCount[c]--
+			}
+			rows[i].addScaled(r, f, gf, &spare)
+			rows[i], spare = spare, rows[i]
+			for _, c := range rows[i].cols {
+				colCount[c]++
+			}
+			gf.MulAdd(data[i], data[p], f)
+			d.ops++
+		}
+		order, pivCol[p] = append(order, p), pc
+	}
+
+	chunks := make([][]byte, k)
+	for s := k - 1; s >= 0; s-- {
+		p := order[s]
+		for i, c := range rows[p].cols {
+			if c != pivCol[p] {
+				gf.MulAdd(data[p], chunks[c], rows[p].vals[i])
+				d.ops++
+			}
+		}
+		chunks[pivCol[p]] = data[p]
+	}
+	d.out = make([]byte, 0, k*len(chunks[0]))
+	for _, b := range chunks {
+		d.out = append(d.out, b...)
+	}
+}
+
+func (d *SparseDecoder) Rank() int {
+	return d.rank
+}
+
+func (d *SparseDecoder) Complete() bool {
+	return d.rank == d.k
+}
+
+// Ops returns the number of payload row operations performed so far.
+func (d *SparseDecoder) Ops() int {
+	if d.dense != nil {
+		return d.dense.Ops()
+	}
+	return d.ops
+}
+
+// Data returns the k source chunks concatenated, or ErrRankDeficient if
+// the rank is short. The payload work all happens in the first call.
+func (d *SparseDecoder) Data() ([]byte, error) {
+	if !d.Complete() {
+		return nil, fmt.Errorf("%w (rank %d/%d)", ErrRankDeficient, d.rank, d.k)
+	}
+	if d.dense != nil {
+		return d.dense.Data()
+	}
+	if d.out == nil {
+		d.solve()
+	}
+	return d.out, nil
+}
+
+// densityResult is one row of the sparse coding tradeoff table.
+type densityResult struct {
+	sparsity   sparsity
+	full       int           // transfers that reached full rank
+	nonZeros   float64       // mean non-zero coefficients per symbol
+	dependent  float64       // fraction of received symbols that were not innovative
+	encodeTime time.Duration // per symbol
+	rrefOps    float64       // payload row ops per decode, ProgressiveDecoder
+	sparseOps  float64       // the same for SparseDecoder
+	rrefTime   time.Duration
+	sparseTime time.Duration
+}
+
+// densityTradeoff measures, for each setting, a lossless point-to-point
+// transfer repeated trials times: symbols are generated until the
+// receiver reaches full rank, or gives up after 20k symbols (in GF(2)
+// even-degree vectors never span the whole space), and both decoders are
+// timed on the same symbols.
+func densityTradeoff(c *Coding, settings []sparsity, trials int, rng *rand.Rand) []densityResult {
+	gf, k := c.GF, c.K
+	var out []densityResult
+	for _, sp := range settings {
+		res := densityResult{sparsity: sp}
+		sent, rank := 0, 0
+		for t := 0; t < trials; t++ {
+			_, srcSyms := encodeFile(c, rng)
+			var syms []Symbol
+			probe := NewSparseDecoder(gf, k)
+			for !probe.Complete() && len(syms) < 20*k {
+				start := time.Now()
+				s := mixSparse(srcSyms, c, sp, rng)
+				res.encodeTime += time.Since(start)
+				syms = append(syms, s)
+				probe.Add(&syms[len(syms)-1])
+			}
+			sent, rank = sent+len(syms), rank+probe.Rank()
+			if probe.Complete() {
+				res.full++
+			}
+			for i := range syms {
+				for j := 0; j < k; j++ {
+					if syms[i].coeffAt(j) != 0 {
+						res.nonZeros++
+					}
+				}
+			}
+
+			start := time.Now()
+			rref := NewProgressiveDecoder(gf, k)
+			for i := range syms {
+				rref.Add(&syms[i])
+			}
+			rref.Data()
+			res.rrefTime += time.Since(start)
+			res.rrefOps += float64(rref.Ops())
+
+			start = time.Now()
+			sparse := NewSparseDecoder(gf, k)
+			for i := range syms {
+				sparse.Add(&syms[i])
+			}
+			sparse.Data()
+			res.sparseTime += time.Since(start)
+			res.sparseOps += float64(sparse.Ops())
+		}
+		n := float64(trials)
+		res.nonZeros /= float64(sent)
+		res.dependent = float64(sent-rank) / float64(sent)
+		res.encodeTime /= time.Duration(sent)
+		res.rrefOps /= n
+		res.sparseOps /= n
+		res.rrefTime /= time.Duration(trials)
+		res.sparseTime /= time.Duration(trials)
+		out = append(out, res)
+	}
+	return out
+}
+
+// printDensityTradeoff prints densityTradeoff results as a markdown table.
+func printDensityTradeoff(results []densityResult) {
+	fmt.Println("\n| Coefficients  | Full rank | Non-zeros | Dependent | Encode/sym  | Dense ops | Sparse ops | Dense decode | Sparse decode |")
+	fmt.Println("|---------------|-----------|-----------|-----------|-------------|-----------|------------|--------------|---------------|")
+	for _, r := range results {
+		fmt.Printf("| %-13s | %-9s | %-9.1f | %8.1f%% | %-11v | %-9.0f | %-10.0f | %-12v | %-13v |\n",
+			r.sparsity, fmt.Sprintf("%d/%d", r.full, sparseTrials), r.nonZeros, 100*r.dependent, r.encodeTime.Round(time.Microsecond),
+			r.rrefOps, r.sparseOps, r.rrefTime.Round(time.Microsecond), r.sparseTime.Round(time.Microsecond))
+	}
+}
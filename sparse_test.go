@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// TestSparseDecoder runs SparseDecoder next to ProgressiveDecoder on the
+// same symbols, at densities that keep rows in entry lists and at ones
+// that fill them in, and checks both recover the source.
+func TestSparseDecoder(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, bits := range []int{1, 8, 16} {
+		c, err := NewCoding(bits, 0, 64, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sp := range []sparsity{{degree: 2}, {density: 0.05}, {density: 0.1}, {density: 0.3}, {density: 1}} {
+			src, srcSyms := encodeFile(c, rng)
+			sparse, dense := NewSparseDecoder(c.GF, c.K), NewProgressiveDecoder(c.GF, c.K)
+			for n := 0; !dense.Complete() && n < 20*c.K; n++ {
+				sym := mixSparse(srcSyms, c, sp, rng)
+				if got, want := sparse.Add(&sym), dense.Add(&sym); got != want {
+					t.Fatalf("GF(2^%d) %v: symbol %d innovative = %v, progressive decoder says %v", bits, sp, n, got, want)
+				}
+				if sparse.Rank() != dense.Rank() {
+					t.Fatalf("GF(2^%d) %v: rank %d, progressive decoder has %d", bits, sp, sparse.Rank(), dense.Rank())
+				}
+			}
+			if !dense.Complete() {
+				// Even degrees never reach full rank in GF(2)
+				continue
+			}
+			out, err := sparse.Data()
+			if err != nil || !bytes.Equal(out, src) {
+				t.Errorf("GF(2^%d) %v: sparse decode does not match the source (err %v)", bits, sp, err)
+			}
+		}
+	}
+}
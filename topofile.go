@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Topology files replay real overlays (e.g. crawled P2P peer graphs).
+// The format is picked by extension:
+//
+//	.graphml, .xml  GraphML; edge <data> keys named "loss", "delay",
+//	                "channel", "jitter", "bandwidth"
+//	.json           JSON adjacency as written by networkx.adjacency_data
+//	anything else   edge list: "a b [loss] [delay] [channel] [jitter]
+//	                [bandwidth]" per line, "-" skips a column, # starts a
+//	                comment
+//
+// Edge lists are undirected; JSON and GraphML follow their "directed"
+// flag, and their edges may only join declared nodes. Delays are Go durations ("5ms") or plain numbers in milliseconds.
+// Channel, jitter and bandwidth take the same specs as the -channel,
+// -jitter and -bandwidth flags; a channel takes precedence over loss.
+// Node order is order of first appearance, so the source (peer 0) is the
+// first node in the file.
+
+// topoBuilder accumulates named nodes and edges while a file is parsed.
+type topoBuilder struct {
+	ids   map[string]int
+	names []string
+	edges []fileEdge
+}
+
+type fileEdge struct {
+	a, b     int
+	directed bool
+	attr     edgeAttr
+}
+
+func newTopoBuilder() *topoBuilder {
+	return &topoBuilder{ids: make(map[string]int)}
+}
+
+func (tb *topoBuilder) node(name string) int {
+	if id, ok := tb.ids[name]; ok {
+		return id
+	}
+	tb.ids[name] = len(tb.names)
+	tb.names = append(tb.names, name)
+	return len(tb.names) - 1
+}
+
+func (tb *topoBuilder) edge(a, b string, directed bool, attr edgeAttr) {
+	tb.edges = append(tb.edges, fileEdge{a: tb.node(a), b: tb.node(b), directed: directed, attr: attr})
+}
+
+func (tb *topoBuilder) graph() (*Graph, error) {
+	if len(tb.names) == 0 {
+		return nil, fmt.Errorf("topology file has no nodes")
+	}
+	g := NewGraph(len(tb.names))
+	g.names = tb.names
+	g.attr = make(map[[2]int]edgeAttr)
+	for _, e := range tb.edges {
+		if e.a == e.b {
+			continue
+		}
+		if e.directed {
+			g.addArc(e.a, e.b)
+		} else {
+			g.addEdge(e.a, e.b)
+			g.attr[[2]int{e.b, e.a}] = e.attr
+		}
+		g.attr[[2]int{e.a, e.b}] = e.attr
+	}
+	return g, nil
+}
+
+// loadTopology reads a topology file in any supported format.
+func loadTopology(path string) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tb := newTopoBuilder()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".graphml", ".xml":
+		err = parseGraphML(f, tb)
+	case ".json":
+		err = parseJSONAdjacency(f, tb)
+	default:
+		err = parseEdgeList(f, tb)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tb.graph()
+}
+
+// parseDelay accepts a Go duration or a finite number of milliseconds,
+// neither negative.
+func parseDelay(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		ms, err := strconv.ParseFloat(s, 64)
+		// NaN and values past the int64 nanosecond range have no Duration
+		if err != nil || !(math.Abs(ms) < math.MaxInt64/float64(time.Millisecond)) {
+			return 0, fmt.Errorf("bad delay %q", s)
+		}
+		d = time.Duration(ms * float64(time.Millisecond))
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative delay %q", s)
+	}
+	return d, nil
+}
+
+// parseLoss parses a loss probability, which must lie in [0,1].
+func parseLoss(s string) (float64, error) {
+	loss, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad loss %q", s)
+	}
+	return loss, checkLoss(loss)
+}
+
+func checkLoss(loss float64) error {
+	if !(loss >= 0 && loss <= 1) {
+		return fmt.Errorf("loss %v outside [0,1]", loss)
+	}
+	return nil
+}
+
+func parseEdgeList(f *os.File, tb *topoBuilder) error {
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := sc.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 1 {
+			// A lone node with no edges
+			tb.node(fields[0])
+			continue
+		}
+		if len(fields) > 7 {
+			return fmt.Errorf("line %d: %d columns, at most 7 allowed", line, len(fields))
+		}
+		attr := newEdgeAttr()
+		if len(fields) > 2 && fields[2] != "-" {
+			loss, err := parseLoss(fields[2])
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			attr.loss = loss
+		}
+		if len(fields) > 3 && fields[3] != "-" {
+			d, err := parseDelay(fields[3])
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			attr.delay = d
+		}
+		if len(fields) > 4 && fields[4] != "-" {
+			ch, err := parseChannel(fields[4], 0)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			attr.channel = ch
+		}
+		if len(fields) > 5 && fields[5] != "-" {
+			j, err := parseJitter(fields[5])
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			attr.jitter = j
+		}
+		if len(fields) > 6 && fields[6] != "-" {
+			bw, err := parseBandwidth(fields[6])
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			attr.bandwidth = bw
+		}
+		tb.edge(fields[0], fields[1], false, attr)
+	}
+	return sc.Err()
+}
+
+// jsonAttr decodes the optional link attributes of a JSON edge. Delay may
+// be a duration string or a number of milliseconds, bandwidth a rate
+// string or a number of bits per second.
+func jsonAttr(m map[string]any) (edgeAttr, error) {
+	attr := newEdgeAttr()
+	if v, ok := m["loss"]; ok {
+		loss, ok := v.(float64)
+		if !ok {
+			return attr, fmt.Errorf("bad loss %v", v)
+		}
+		if err := checkLoss(loss); err != nil {
+			return attr, err
+		}
+		attr.loss = loss
+	}
+	if v, ok := m["delay"]; ok {
+		d, err := parseDelay(fmt.Sprint(v))
+		if err != nil {
+			return attr, err
+		}
+		attr.delay = d
+	}
+	if v, ok := m["channel"]; ok {
+		ch, err := parseChannel(fmt.Sprint(v), 0)
+		if err != nil {
+			return attr, err
+		}
+		attr.channel = ch
+	}
+	if v, ok := m["jitter"]; ok {
+		j, err := parseJitter(fmt.Sprint(v))
+		if err != nil {
+			return attr, err
+		}
+		attr.jitter = j
+	}
+	if v, ok := m["bandwidth"]; ok {
+		bw, err := parseBandwidth(fmt.Sprint(v))
+		if err != nil {
+			return attr, err
+		}
+		attr.bandwidth = bw
+	}
+	return attr, nil
+}
+
+func parseJSONAdjacency(f *os.File, tb *topoBuilder) error {
+	var doc struct {
+		Directed  bool               `json:"directed"`
+		Nodes     []map[string]any   `json:"nodes"`
+		Adjacency [][]map[string]any `json:"adjacency"`
+	}
+	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+		return err
+	}
+	if len(doc.Adjacency) != len(doc.Nodes) {
+		return fmt.Errorf("%d nodes but %d adjacency lists", len(doc.Nodes), len(doc.Adjacency))
+	}
+	names := make([]string, len(doc.Nodes))
+	for i, n := range doc.Nodes {
+		id, ok := n["id"]
+		if !ok {
+			return fmt.Errorf("node %d has no id", i)
+		}
+		names[i] = fmt.Sprint(id)
+		tb.node(names[i])
+	}
+	for i, nbrs := range doc.Adjacency {
+		for _, nb := range nbrs {
+			id, ok := nb["id"]
+			if !ok {
+				return fmt.Errorf("neighbor of %s has no id", names[i])
+			}
+			if _, ok := tb.ids[fmt.Sprint(id)]; !ok {
+				return fmt.Errorf("edge %d (%s-%v): unknown node %v", len(tb.edges)+1, names[i], id, id)
+			}
+			attr, err := jsonAttr(nb)
+			if err != nil {
+				return fmt.Errorf("edge %d (%s-%v): %w", len(tb.edges)+1, names[i], id, err)
+			}
+			tb.edge(names[i], fmt.Sprint(id), doc.Directed, attr)
+		}
+	}
+	return nil
+}
+
+func parseGraphML(f *os.File, tb *topoBuilder) error {
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	var doc struct {
+		Keys []struct {
+			ID   string `xml:"id,attr"`
+			For  string `xml:"for,attr"`
+			Name string `xml:"attr.name,attr"`
+		} `xml:"key"`
+		Graph struct {
+			EdgeDefault string `xml:"edgedefault,attr"`
+			Nodes       []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source   string `xml:"source,attr"`
+				Target   string `xml:"target,attr"`
+				Directed string `xml:"directed,attr"`
+				Data     []data `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.NewDecoder(f).Decode(&doc); err != nil {
+		return err
+	}
+
+	// Map key ids to attribute names, e.g. d0 -> loss
+	keys := make(map[string]string)
+	for _, k := range doc.Keys {
+		if k.For == "edge" || k.For == "all" {
+			keys[k.ID] = k.Name
+		}
+	}
+	for _, n := range doc.Graph.Nodes {
+		tb.node(n.ID)
+	}
+	for i, e := range doc.Graph.Edges {
+		// Errors name the edge by its position and endpoints
+		where := fmt.Sprintf("edge %d (%s-%s)", i+1, e.Source, e.Target)
+		for _, id := range []string{e.Source, e.Target} {
+			if _, ok := tb.ids[id]; !ok {
+				return fmt.Errorf("%s: unknown node %q", where, id)
+			}
+		}
+		directed := doc.Graph.EdgeDefault == "directed"
+		if e.Directed != "" {
+			directed = e.Directed == "true"
+		}
+		attr := newEdgeAttr()
+		for _, d := range e.Data {
+			v := strings.TrimSpace(d.Value)
+			switch keys[d.Key] {
+			case "loss":
+				loss, err := parseLoss(v)
+				if err != nil {
+					return fmt.Errorf("%s: %w", where, err)
+				}
+				attr.loss = loss
+			case "delay":
+				delay, err := parseDelay(v)
+				if err != nil {
+					return fmt.Errorf("%s: %w", where, err)
+				}
+				attr.delay = delay
+			case "channel":
+				ch, err := parseChannel(v, 0)
+				if err != nil {
+					return fmt.Errorf("%s: %w", where, err)
+				}
+				attr.channel = ch
+			case "jitter":
+				j, err := parseJitter(v)
+				if err != nil {
+					return fmt.Errorf("%s: %w", where, err)
+				}
+				attr.jitter = j
+			case "bandwidth":
+				bw, err := parseBandwidth(v)
+				if err != nil {
+					return fmt.Errorf("%s: %w", where, err)
+				}
+				attr.bandwidth = bw
+			}
+		}
+		tb.edge(e.Source, e.Target, directed, attr)
+	}
+	return nil
+}
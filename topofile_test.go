@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTopology writes a fixture into a fresh temporary directory and
+// returns its path; the name's extension picks the parser.
+func writeTopology(t *testing.T, name, body string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+const graphMLHead = `<graphml>
+<key id="d0" for="edge" attr.name="loss"/>
+<key id="d1" for="edge" attr.name="delay"/>
+<key id="d2" for="edge" attr.name="jitter"/>
+`
+
+func TestTopologyFiles(t *testing.T) {
+	for _, tc := range []struct {
+		name, body string
+		n          int
+		back       bool // b has an arc back to the source a
+	}{
+		{"g.txt", "# crawl\na b 0.1 5ms\nb c - 2 # comment\nd\n", 4, true},
+		{"g.json", `{"directed": true, "nodes": [{"id": "a"}, {"id": "b"}, {"id": "c"}],
+			"adjacency": [[{"id": "b", "loss": 0.1, "delay": "5ms"}], [{"id": "c", "delay": 2}], []]}`, 3, false},
+		{"g.graphml", graphMLHead + `<graph edgedefault="undirected">
+<node id="a"/><node id="b"/><node id="c"/>
+<edge source="a" target="b"><data key="d0">0.1</data><data key="d1">5ms</data></edge>
+<edge source="b" target="c"><data key="d1">2</data></edge>
+</graph></graphml>`, 3, true},
+	} {
+		g, err := loadTopology(writeTopology(t, tc.name, tc.body))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if g.n != tc.n || g.name(0) != "a" {
+			t.Fatalf("%s: %d nodes, source %s; want %d, source a", tc.name, g.n, g.name(0), tc.n)
+		}
+		if !g.hasArc(0, 1) || !g.hasArc(1, 2) || g.hasArc(1, 0) != tc.back {
+			t.Errorf("%s: arcs %v", tc.name, g.adj)
+		}
+		ab, bc := g.attr[[2]int{0, 1}], g.attr[[2]int{1, 2}]
+		if ab.loss != 0.1 || ab.delay != 5*time.Millisecond || bc.loss != -1 || bc.delay != 2*time.Millisecond {
+			t.Errorf("%s: a-b %+v, b-c %+v", tc.name, ab, bc)
+		}
+	}
+}
+
+func TestTopologyFileRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"loss.txt", "a b\nb c x\n", "line 2: bad loss"},
+		{"lossrange.txt", "a b 1.5\n", "line 1: loss 1.5 outside [0,1]"},
+		{"negloss.txt", "# header\n\na b -0.5\n", "line 3: loss -0.5"},
+		{"delay.txt", "a b - soon\n", "line 1: bad delay"},
+		{"negdelay.txt", "a b 0.1 -5ms\n", "line 1: negative delay"},
+		{"negjitter.txt", "a b - - - -1ms\n", "line 1:"},
+		{"columns.txt", "a b 0.1 1ms - - 1Mbps extra\n", "line 1: 8 columns"},
+
+		{"nan.json", `{"nodes": [{"id": 1}, {"id": 2}], "adjacency": [[{"id": 2, "loss": "NaN"}], []]}`, "edge 1 (1-2): bad loss"},
+		{"loss.json", `{"nodes": [{"id": 1}, {"id": 2}], "adjacency": [[{"id": 2, "loss": 2}], []]}`, "edge 1 (1-2): loss 2 outside"},
+		{"delay.json", `{"nodes": [{"id": 1}, {"id": 2}], "adjacency": [[{"id": 2}], [{"id": 1, "delay": -3}]]}`, "edge 2 (2-1): negative delay"},
+		{"unknown.json", `{"nodes": [{"id": 1}, {"id": 2}], "adjacency": [[{"id": 3}], []]}`, "edge 1 (1-3): unknown node 3"},
+		{"noid.json", `{"nodes": [{"id": 1}], "adjacency": [[{"loss": 0.1}]]}`, "neighbor of 1 has no id"},
+		{"lists.json", `{"nodes": [{"id": 1}, {"id": 2}], "adjacency": [[]]}`, "2 nodes but 1 adjacency lists"},
+		{"syntax.json", `{"nodes": [`, "unexpected EOF"},
+
+		{"loss.graphml", graphMLHead + `<graph><node id="a"/><node id="b"/>
+<edge source="a" target="b"><data key="d0">-0.1</data></edge></graph></graphml>`, "edge 1 (a-b): loss -0.1 outside"},
+		{"delay.graphml", graphMLHead + `<graph><node id="a"/><node id="b"/>
+<edge source="a" target="b"/><edge source="b" target="a"><data key="d1">-1ms</data></edge></graph></graphml>`, "edge 2 (b-a): negative delay"},
+		{"jitter.graphml", graphMLHead + `<graph><node id="a"/><node id="b"/>
+<edge source="a" target="b"><data key="d2">-2ms</data></edge></graph></graphml>`, "edge 1 (a-b):"},
+		{"unknown.graphml", graphMLHead + `<graph><node id="a"/><node id="b"/>
+<edge source="a" target="c"/></graph></graphml>`, `edge 1 (a-c): unknown node "c"`},
+		{"empty.graphml", `<graphml><graph/></graphml>`, "no nodes"},
+	} {
+		_, err := loadTopology(writeTopology(t, tc.name, tc.body))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+// TestDirectedSourceLeftOut runs every scheme on a directed chain with no
+// arc back to the source: the results cover the two receivers only. RS
+// receivers get all 2k shards but count only k as innovative.
+func TestDirectedSourceLeftOut(t *testing.T) {
+	g, err := loadTopology(writeTopology(t, "chain.json", `{"directed": true, "nodes": [{"id": "a"}, {"id": "b"}, {"id": "c"}],
+		"adjacency": [[{"id": "b"}], [{"id": "c"}], []]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := NewCoding(8, 0, 16, 64)
+	newChannel, _ := parseChannel("", 0)
+	sp := simParams{graph: g, channel: newChannel, delay: time.Millisecond, sparsity: sparsity{density: 1}}
+	for _, code := range []scheme{schemeRLNC, schemeRS, schemePlain, schemeLT, schemeRaptor} {
+		innov, _, _, decodes := simulate(code, sp, c, rand.New(rand.NewSource(1)))
+		if len(decodes) != 2 || decodes[0].peer != 1 || decodes[1].peer != 2 {
+			t.Fatalf("%v: results for %d peers, want peers 1 and 2", code, len(decodes))
+		}
+		if countDecoded(decodes) != 2 {
+			t.Errorf("%v: %d/2 receivers decoded", code, countDecoded(decodes))
+		}
+		if code == schemeRS && innov != float64(c.K) {
+			t.Errorf("RS: %.1f innovative shards per receiver, want %d", innov, c.K)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Graph is the overlay the gossip runs on. adj[i] lists the peers that
+// peer i sends to; undirected generators add both directions. Self-loops
+// and duplicate edges are never added.
+type Graph struct {
+	n     int
+	adj   [][]int
+	set   []map[int]bool
+	names []string            // node names from a topology file, nil for generated graphs
+	attr  map[[2]int]edgeAttr // per-arc overrides from a topology file
+}
+
+// edgeAttr overrides the global link settings for one arc. Negative
+// values and nil channel/jitter mean "use the default".
+type edgeAttr struct {
+	loss      float64
+	delay     time.Duration
+	channel   func() Channel // takes precedence over loss
+	jitter    Jitter
+	bandwidth float64 // bits per second, 0 for unlimited
+}
+
+// newEdgeAttr returns an edgeAttr that overrides nothing.
+func newEdgeAttr() edgeAttr {
+	return edgeAttr{loss: -1, delay: -1, bandwidth: -1}
+}
+
+// name returns the display name of node i.
+func (g *Graph) name(i int) string {
+	if g.names != nil {
+		return g.names[i]
+	}
+	return strconv.Itoa(i)
+}
+
+// newLink builds the link for arc a->b from the defaults in sp, with any
+// overrides the topology file set for that arc. The caller fills in to.
+func (g *Graph) newLink(a, b int, sp simParams) *Link {
+	newChannel := sp.channel
+	l := &Link{delay: sp.delay, jitter: sp.jitter, bandwidth: sp.bandwidth}
+	if at, ok := g.attr[[2]int{a, b}]; ok {
+		switch {
+		case at.channel != nil:
+			newChannel = at.channel
+		case at.loss >= 0:
+			loss := at.loss
+			newChannel = func() Channel { return &Bernoulli{P: loss} }
+		}
+		if at.delay >= 0 {
+			l.delay = at.delay
+		}
+		if at.jitter != nil {
+			l.jitter = at.jitter
+		}
+		if at.bandwidth >= 0 {
+			l.bandwidth = at.bandwidth
+		}
+	}
+	l.ch = newChannel()
+	return l
+}
+
+// Unreachable returns the nodes that cannot be reached from src by
+// following arcs.
+func (g *Graph) Unreachable(src int) []int {
+	seen := make([]bool, g.n)
+	seen[src] = true
+	queue := []int{src}
+	for len(queue) > 0 {
+		a := queue[0]
+		queue = queue[1:]
+		for _, b := range g.adj[a] {
+			if !seen[b] {
+				seen[b] = true
+				queue = append(queue, b)
+			}
+		}
+	}
+	var out []int
+	for i, ok := range seen {
+		if !ok {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func NewGraph(n int) *Graph {
+	g := &Graph{n: n, adj: make([][]int, n), set: make([]map[int]bool, n)}
+	for i := range g.set {
+		g.set[i] = make(map[int]bool)
+	}
+	return g
+}
+
+// addArc adds the directed edge a->b and reports whether it was new.
+func (g *Graph) addArc(a, b int) bool {
+	if a == b || g.set[a][b] {
+		return false
+	}
+	g.set[a][b] = true
+	g.adj[a] = append(g.adj[a], b)
+	return true
+}
+
+// addEdge adds the undirected edge a<->b and reports whether it was new.
+func (g *Graph) addEdge(a, b int) bool {
+	if a == b || g.set[a][b] {
+		return false
+	}
+	g.addArc(a, b)
+	g.addArc(b, a)
+	return true
+}
+
+func (g *Graph) hasArc(a, b int) bool {
+	return g.set[a][b]
+}
+
+// Arcs returns the number of directed edges.
+func (g *Graph) Arcs() int {
+	n := 0
+	for _, a := range g.adj {
+		n += len(a)
+	}
+	return n
+}
+
+// topologies lists the generators accepted by -topology.
+var topologies = []string{"random", "regular", "er", "ba", "ring", "line", "grid", "full", "star"}
+
+// buildTopology generates an n-node graph. degree is the fanout for
+// "random", the degree for "regular" and the edges per new node for
+// "ba"; edgeProb is the Erdős–Rényi edge probability.
+func buildTopology(kind string, n, degree int, edgeProb float64, rng *rand.Rand) (*Graph, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("topology needs at least one peer, got %d", n)
+	}
+	g := NewGraph(n)
+	switch kind {
+	case "random":
+		// Each peer picks `degree` distinct random out-neighbors
+		d := min(degree, n-1)
+		for a := 0; a < n; a++ {
+			for len(g.adj[a]) < d {
+				g.addArc(a, rng.Intn(n))
+			}
+		}
+	case "regular":
+		if err := randomRegular(g, degree, rng); err != nil {
+			return nil, err
+		}
+	case "er":
+		for a := 0; a < n; a++ {
+			for b := a + 1; b < n; b++ {
+				if rng.Float64() < edgeProb {
+					g.addEdge(a, b)
+				}
+			}
+		}
+	case "ba":
+		barabasiAlbert(g, degree, rng)
+	case "ring":
+		for a := 0; a < n && n > 1; a++ {
+			g.addEdge(a, (a+1)%n)
+		}
+	case "line":
+		for a := 0; a+1 < n; a++ {
+			g.addEdge(a, a+1)
+		}
+	case "grid":
+		cols := int(math.Ceil(math.Sqrt(float64(n))))
+		for a := 0; a < n; a++ {
+			if (a+1)%cols != 0 && a+1 < n {
+				g.addEdge(a, a+1)
+			}
+			if a+cols < n {
+				g.addEdge(a, a+cols)
+			}
+		}
+	case "full":
+		for a := 0; a < n; a++ {
+			for b := a + 1; b < n; b++ {
+				g.addEdge(a, b)
+			}
+		}
+	case "star":
+		for b := 1; b < n; b++ {
+			g.addEdge(0, b)
+		}
+	default:
+		return nil, fmt.Errorf("unknown topology %q (want one of %v)", kind, topologies)
+	}
+	return g, nil
+}
+
+// randomRegular builds a random d-regular graph by pairing random stubs,
+// restarting whenever the remaining stubs can only form self-loops or
+// duplicate edges.
+func randomRegular(g *Graph, d int, rng *rand.Rand) error {
+	n := g.n
+	if d < 1 || d >= n || n*d%2 != 0 {
+		return fmt.Errorf("no %d-regular graph on %d peers (need 0 < degree < peers and peers*degree even)", d, n)
+	}
+	for attempt := 0; attempt < 100; attempt++ {
+		*g = *NewGraph(n)
+		stubs := make([]int, 0, n*d)
+		for a := 0; a < n; a++ {
+			for i := 0; i < d; i++ {
+				stubs = append(stubs, a)
+			}
+		}
+		for len(stubs) > 0 {
+			ok := false
+			for try := 0; try < 50; try++ {
+				i, j := rng.Intn(len(stubs)), rng.Intn(len(stubs))
+				if i == j || !g.addEdge(stubs[i], stubs[j]) {
+					continue
+				}
+				// Remove both stubs, higher index first
+				if i < j {
+					i, j = j, i
+				}
+				stubs[i] = stubs[len(stubs)-1]
+				stubs = stubs[:len(stubs)-1]
+				stubs[j] = stubs[len(stubs)-1]
+				stubs = stubs[:len(stubs)-1]
+				ok = true
+				break
+			}
+			if !ok {
+				break
+			}
+		}
+		if len(stubs) == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to generate a %d-regular graph on %d peers", d, n)
+}
+
+// barabasiAlbert grows a preferential-attachment graph: it starts from a
+// clique of m+1 peers and attaches every further peer to m distinct
+// existing peers chosen with probability proportional to their degree.
+func barabasiAlbert(g *Graph, m int, rng *rand.Rand) {
+	m = max(1, min(m, g.n-1))
+	var ends []int // every edge endpoint once, for degree-weighted sampling
+	for a := 0; a <= m && a < g.n; a++ {
+		for b := a + 1; b <= m && b < g.n; b++ {
+			g.addEdge(a, b)
+			ends = append(ends, a, b)
+		}
+	}
+	for a := m + 1; a < g.n; a++ {
+		added := 0
+		for added < m {
+			b := ends[rng.Intn(len(ends))]
+			if g.addEdge(a, b) {
+				added++
+			}
+		}
+		for _, b := range g.adj[a] {
+			ends = append(ends, a, b)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// checkSimple fails on self-loops and on arcs adj and set disagree about.
+func checkSimple(t *testing.T, name string, g *Graph) {
+	t.Helper()
+	for a, nbrs := range g.adj {
+		if len(g.set[a]) != len(nbrs) {
+			t.Fatalf("%s: peer %d has %d arcs but %d distinct", name, a, len(nbrs), len(g.set[a]))
+		}
+		for _, b := range nbrs {
+			if a == b {
+				t.Fatalf("%s: self-loop at %d", name, a)
+			}
+		}
+	}
+}
+
+func TestTopologyDegrees(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const n = 20
+	for _, tc := range []struct {
+		kind      string
+		degree    int
+		edgeProb  float64
+		minDeg    int // out-degree bounds of every peer
+		maxDeg    int
+		symmetric bool
+	}{
+		{"random", 3, 0, 3, 3, false},
+		{"regular", 3, 0, 3, 3, true},
+		{"regular", 4, 0, 4, 4, true},
+		{"er", 0, 1, n - 1, n - 1, true},
+		{"ba", 2, 0, 2, n - 1, true},
+		{"ring", 0, 0, 2, 2, true},
+		{"line", 0, 0, 1, 2, true},
+		{"grid", 0, 0, 2, 4, true},
+		{"full", 0, 0, n - 1, n - 1, true},
+		{"star", 0, 0, 1, n - 1, true},
+	} {
+		g, err := buildTopology(tc.kind, n, tc.degree, tc.edgeProb, rng)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.kind, err)
+		}
+		checkSimple(t, tc.kind, g)
+		for a, nbrs := range g.adj {
+			if len(nbrs) < tc.minDeg || len(nbrs) > tc.maxDeg {
+				t.Errorf("%s: peer %d has degree %d, want %d..%d", tc.kind, a, len(nbrs), tc.minDeg, tc.maxDeg)
+			}
+			for _, b := range nbrs {
+				if tc.symmetric && !g.hasArc(b, a) {
+					t.Errorf("%s: arc %d->%d has no reverse", tc.kind, a, b)
+				}
+			}
+		}
+		// Random out-neighbors need not reach everyone; the others do here
+		if un := g.Unreachable(0); len(un) > 0 && tc.kind != "random" {
+			t.Errorf("%s: peers %v unreachable from the source", tc.kind, un)
+		}
+	}
+
+	g, _ := buildTopology("er", n, 0, 0, rng)
+	if g.Arcs() != 0 {
+		t.Errorf("er with edge probability 0 has %d arcs", g.Arcs())
+	}
+	g, _ = buildTopology("star", n, 0, 0, rng)
+	if len(g.adj[0]) != n-1 {
+		t.Errorf("star center has degree %d, want %d", len(g.adj[0]), n-1)
+	}
+}
+
+func TestTopologyRejects(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, tc := range []struct {
+		kind      string
+		n, degree int
+	}{
+		{"regular", 5, 3},  // odd peers*degree
+		{"regular", 7, 1},  // odd peers*degree
+		{"regular", 4, 4},  // degree not below peers
+		{"regular", 2, -1}, // negative degree
+		{"regular", 4, 0},
+		{"random", 0, 2},
+		{"hypercube", 8, 3},
+	} {
+		if _, err := buildTopology(tc.kind, tc.n, tc.degree, 0.5, rng); err == nil {
+			t.Errorf("%s with %d peers, degree %d: no error", tc.kind, tc.n, tc.degree)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrOversized is returned by Recv for a datagram larger than any valid
+// wire packet, which the transport cannot have received whole.
+var ErrOversized = errors.New("transport: datagram larger than any wire packet")
+
+// Transport carries wire packets (wire.go) between live peers, addressed
+// by string. Delivery is best effort in both implementations: a packet
+// may be dropped without an error, and RLNC does not care which one.
+type Transport interface {
+	// Send queues pkt for the peer at addr.
+	Send(addr string, pkt []byte) error
+	// Recv blocks for the next packet until deadline, then returns
+	// os.ErrDeadlineExceeded. A packet that cannot be received whole is
+	// reported as ErrOversized rather than passed on truncated.
+	Recv(deadline time.Time) ([]byte, error)
+	// Addr is the address other peers send to.
+	Addr() string
+	Close() error
+}
+
+// memInboxSize is how many packets an in-memory peer queues before
+// further packets to it are dropped, like a full socket buffer.
+const memInboxSize = 10000
+
+// MemNetwork connects in-process peers through buffered Go channels, one
+// inbox per address.
+type MemNetwork struct {
+	mu      sync.Mutex
+	inboxes map[string]chan []byte
+}
+
+func NewMemNetwork() *MemNetwork {
+	return &MemNetwork{inboxes: make(map[string]chan []byte)}
+}
+
+// Listen creates the transport for addr, which must not be in use.
+func (n *MemNetwork) Listen(addr string) (*MemTransport, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.inboxes[addr]; ok {
+		return nil, fmt.Errorf("address %s already in use", addr)
+	}
+	inbox := make(chan []byte, memInboxSize)
+	n.inboxes[addr] = inbox
+	return &MemTransport{net: n, addr: addr, inbox: inbox}, nil
+}
+
+// MemTransport is one peer's endpoint on a MemNetwork.
+type MemTransport struct {
+	net   *MemNetwork
+	addr  string
+	inbox chan []byte
+}
+
+// Send drops pkt if addr is unknown or closed, or its inbox is full.
+func (t *MemTransport) Send(addr string, pkt []byte) error {
+	t.net.mu.Lock()
+	inbox := t.net.inboxes[addr]
+	t.net.mu.Unlock()
+	if inbox == nil {
+		return nil
+	}
+	select {
+	case inbox <- pkt:
+	default:
+	}
+	return nil
+}
+
+func (t *MemTransport) Recv(deadline time.Time) ([]byte, error) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case pkt := <-t.inbox:
+		return pkt, nil
+	case <-timer.C:
+		return nil, os.ErrDeadlineExceeded
+	}
+}
+
+func (t *MemTransport) Addr() string {
+	return t.addr
+}
+
+// Close unregisters the address; packets sent to it afterwards vanish.
+func (t *MemTransport) Close() error {
+	t.net.mu.Lock()
+	delete(t.net.inboxes, t.addr)
+	t.net.mu.Unlock()
+	return nil
+}
+
+// UDPTransport sends one packet per datagram.
+type UDPTransport struct {
+	conn  *net.UDPConn
+	addrs map[string]*net.UDPAddr // resolved neighbor addresses
+	buf   []byte                  // one byte over maxPacketSize, so oversized datagrams show
+}
+
+// ListenUDP binds a UDP socket to addr, e.g. "127.0.0.1:9000".
+func ListenUDP(addr string) (*UDPTransport, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", a)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPTransport{conn: conn, addrs: make(map[string]*net.UDPAddr), buf: make([]byte, maxPacketSize+1)}, nil
+}
+
+// Send fails for packets over the 65507-byte UDP payload limit. A
+// neighbor that is not (or no longer) listening is not an error.
+func (t *UDPTransport) Send(addr string, pkt []byte) error {
+	a, ok := t.addrs[addr]
+	if !ok {
+		var err error
+		if a, err = net.ResolveUDPAddr("udp", addr); err != nil {
+			return err
+		}
+		t.addrs[addr] = a
+	}
+	if _, err := t.conn.WriteToUDP(pkt, a); err != nil && !isConnRefused(err) {
+		return err
+	}
+	return nil
+}
+
+func (t *UDPTransport) Recv(deadline time.Time) ([]byte, error) {
+	if err := t.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for {
+		n, from, err := t.conn.ReadFromUDP(t.buf)
+		if err != nil {
+			// A refused earlier send surfaces here on some systems
+			if isConnRefused(err) {
+				continue
+			}
+			return nil, err
+		}
+		if n == len(t.buf) {
+			return nil, fmt.Errorf("%w: from %v", ErrOversized, from)
+		}
+		return append([]byte(nil), t.buf[:n]...), nil
+	}
+}
+
+func (t *UDPTransport) Addr() string {
+	return t.conn.LocalAddr().String()
+}
+
+func (t *UDPTransport) Close() error {
+	return t.conn.Close()
+}
+
+// isConnRefused reports an ICMP port-unreachable from a peer that has
+// already exited.
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
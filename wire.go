@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Wire format for a coded symbol, so the same packet can go over a
+// socket, into a file or through the simulator. All integers are
+// big-endian:
+//
+//	offset  size  field
+//	0       2     magic "NC"
+//	2       1     version (2)
+//	3       1     field ID: 1, 8 or 16 (bits of GF(2^m))
+//	4       1     coefficient encoding: 0 dense, 1 seed, 2 sparse
+//	5       1     reserved, 0
+//	6       4     field polynomial, e.g. 0x11d
+//	10      4     generation ID
+//	14      2     k, source symbols in the generation
+//	16      2     symbol size in bytes
+//	18      ...   coefficients, see below
+//	...     size  payload
+//	end-4   4     CRC32C (Castagnoli) of everything before it
+//
+// Dense coefficients are k field elements: k bits rounded up to bytes in
+// GF(2) (bit i of byte i/8, least significant first), k bytes in GF(2^8),
+// 2k bytes in GF(2^16). Seed is a u32 for seedCoeffs. Sparse is a u16
+// count n, n increasing u16 column indices, then n non-zero values of
+// the field's width (omitted in GF(2), where every listed value is 1).
+//
+// A packet describes itself: the receiver needs the field to check it
+// against, but takes k and the symbol size from the header.
+
+const (
+	wireMagic      = "NC"
+	wireVersion    = 2
+	wireHeaderSize = 18
+	wireCRCSize    = 4
+
+	// maxPacketSize bounds any valid packet: the largest sparse vector
+	// (65535 two-byte indices and values) plus the largest payload.
+	maxPacketSize = wireHeaderSize + 2 + 4*0xffff + 0xffff + wireCRCSize
+)
+
+// CoeffEncoding is how a packet carries the coefficient vector.
+type CoeffEncoding uint8
+
+const (
+	EncodingDense  CoeffEncoding = iota // all k coefficients
+	EncodingSeed                        // a seed to regenerate them from
+	EncodingSparse                      // the non-zero coefficients with their indices
+)
+
+func (e CoeffEncoding) String() string {
+	switch e {
+	case EncodingDense:
+		return "dense"
+	case EncodingSeed:
+		return "seed"
+	case EncodingSparse:
+		return "sparse"
+	}
+	return fmt.Sprintf("encoding(%d)", uint8(e))
+}
+
+var (
+	ErrBadPacket   = errors.New("wire: malformed packet")
+	ErrBadChecksum = errors.New("wire: checksum mismatch")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// elemBytes is the width of one field element on the wire; GF(2)
+// elements are packed or implicit, so 0.
+func elemBytes(gf *GF) int {
+	if gf.bits == 1 {
+		return 0
+	}
+	return gf.WordSize()
+}
+
+// wireEncoding picks the coefficient encoding for sym: seed if it has
+// one, otherwise whichever of dense and sparse is smaller.
+func wireEncoding(sym *Symbol, c *Coding) CoeffEncoding {
+	if sym.Seeded {
+		return EncodingSeed
+	}
+	nz := 0
+	for i := 0; i < c.K; i++ {
+		if sym.coeffAt(i) != 0 {
+			nz++
+		}
+	}
+	if 2+nz*(2+elemBytes(c.GF)) < c.coeffBytes() {
+		return EncodingSparse
+	}
+	return EncodingDense
+}
+
+// Marshal encodes sym as a packet over c, choosing the coefficient
+// encoding with wireEncoding.
+func Marshal(sym *Symbol, c *Coding) ([]byte, error) {
+	if c.K > 0xffff || len(sym.Data) > 0xffff || sym.Gen < 0 || int64(sym.Gen) > 0xffffffff {
+		return nil, fmt.Errorf("wire: k=%d, symbol size %d or generation %d does not fit the header", c.K, len(sym.Data), sym.Gen)
+	}
+	enc := wireEncoding(sym, c)
+	b := make([]byte, 0, wireHeaderSize+c.coeffBytes()+len(sym.Data)+wireCRCSize)
+	b = append(b, wireMagic...)
+	b = append(b, wireVersion, byte(c.bits), byte(enc), 0)
+	b = binary.BigEndian.AppendUint32(b, uint32(c.poly))
+	b = binary.BigEndian.AppendUint32(b, uint32(sym.Gen))
+	b = binary.BigEndian.AppendUint16(b, uint16(c.K))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(sym.Data)))
+
+	appendElem := func(v uint16) {
+		switch elemBytes(c.GF) {
+		case 1:
+			b = append(b, byte(v))
+		case 2:
+			b = binary.BigEndian.AppendUint16(b, v)
+		}
+	}
+	switch enc {
+	case EncodingSeed:
+		b = binary.BigEndian.AppendUint32(b, sym.Seed)
+	case EncodingSparse:
+		var cols []int
+		for i := 0; i < c.K; i++ {
+			if sym.coeffAt(i) != 0 {
+				cols = append(cols, i)
+			}
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(len(cols)))
+		for _, i := range cols {
+			b = binary.BigEndian.AppendUint16(b, uint16(i))
+		}
+		for _, i := range cols {
+			appendElem(sym.coeffAt(i))
+		}
+	default:
+		if c.bits == 1 {
+			packed := make([]byte, c.coeffBytes())
+			for i := 0; i < c.K; i++ {
+				packed[i/8] |= byte(sym.coeffAt(i)) << (i % 8)
+			}
+			b = append(b, packed...)
+		} else {
+			for i := 0; i < c.K; i++ {
+				appendElem(sym.coeffAt(i))
+			}
+		}
+	}
+	b = append(b, sym.Data...)
+	return binary.BigEndian.AppendUint32(b, crc32.Checksum(b, castagnoli)), nil
+}
+
+// Header is the fixed part of a packet.
+type Header struct {
+	Field    int // bits of GF(2^m)
+	Poly     int
+	Encoding CoeffEncoding
+	Gen      int
+	K        int // source symbols in the generation
+	Size     int // payload bytes
+}
+
+// ParseHeader reads the header of b without checking the CRC or the
+// coefficients, e.g. to learn the geometry before decoding.
+func ParseHeader(b []byte) (Header, error) {
+	if len(b) < wireHeaderSize+wireCRCSize || string(b[:2]) != wireMagic {
+		return Header{}, ErrBadPacket
+	}
+	if b[2] != wireVersion {
+		return Header{}, fmt.Errorf("%w: unsupported version %d", ErrBadPacket, b[2])
+	}
+	h := Header{
+		Field:    int(b[3]),
+		Encoding: CoeffEncoding(b[4]),
+		Poly:     int(binary.BigEndian.Uint32(b[6:])),
+		Gen:      int(binary.BigEndian.Uint32(b[10:])),
+		K:        int(binary.BigEndian.Uint16(b[14:])),
+		Size:     int(binary.BigEndian.Uint16(b[16:])),
+	}
+	if h.K == 0 || h.Size == 0 {
+		return Header{}, fmt.Errorf("%w: k=%d, symbol size %d", ErrBadPacket, h.K, h.Size)
+	}
+	return h, nil
+}
+
+// Unmarshal decodes a packet into a symbol over gf, with k coefficients
+// and a payload of the size its header gives. The packet's field and
+// polynomial must be gf's. Seeded symbols come back with their
+// coefficients regenerated and Seeded set, so they can be used directly
+// or forwarded compactly. The payload aliases b.
+func Unmarshal(b []byte, gf *GF) (Symbol, Header, error) {
+	h, err := ParseHeader(b)
+	if err != nil {
+		return Symbol{}, Header{}, err
+	}
+	sym, err := unmarshal(b, h, gf)
+	if err != nil {
+		return Symbol{}, Header{}, err
+	}
+	return sym, h, nil
+}
+
+// unmarshalFor decodes a packet that must also match c's k and symbol
+// size and belong to one of gens generations, for receivers whose decoder
+// is already set up.
+func unmarshalFor(b []byte, c *Coding, gens int) (Symbol, error) {
+	sym, h, err := Unmarshal(b, c.GF)
+	if err != nil {
+		return Symbol{}, err
+	}
+	if h.K != c.K || h.Size != c.Size {
+		return Symbol{}, fmt.Errorf("%w: packet has k=%d and %d B symbols, want k=%d and %d B", ErrBadPacket, h.K, h.Size, c.K, c.Size)
+	}
+	if h.Gen >= gens {
+		return Symbol{}, fmt.Errorf("%w: generation %d of %d", ErrBadPacket, h.Gen, gens)
+	}
+	return sym, nil
+}
+
+func unmarshal(b []byte, h Header, gf *GF) (Symbol, error) {
+	body := b[:len(b)-wireCRCSize]
+	if crc32.Checksum(body, castagnoli) != binary.BigEndian.Uint32(b[len(body):]) {
+		return Symbol{}, ErrBadChecksum
+	}
+	if h.Field != gf.bits || h.Poly != gf.poly {
+		return Symbol{}, fmt.Errorf("%w: packet is over GF(2^%d) mod %#x, want GF(2^%d) mod %#x", ErrBadPacket, h.Field, h.Poly, gf.bits, gf.poly)
+	}
+	if h.Size%gf.WordSize() != 0 {
+		return Symbol{}, fmt.Errorf("%w: %d B symbols in GF(2^%d)", ErrBadPacket, h.Size, gf.bits)
+	}
+	// The geometry comes from the packet, not the receiver
+	c := &Coding{GF: gf, K: h.K, Size: h.Size}
+	enc, size := h.Encoding, h.Size
+	sym := Symbol{Gen: h.Gen}
+
+	if len(body)-size < wireHeaderSize {
+		return Symbol{}, ErrBadPacket
+	}
+	coeffs := body[wireHeaderSize : len(body)-size]
+	sym.Data = body[len(body)-size:]
+	elem := func(e []byte) uint16 {
+		if elemBytes(c.GF) == 2 {
+			return binary.BigEndian.Uint16(e)
+		}
+		return uint16(e[0])
+	}
+	set := func(i int, v uint16) {
+		if c.bits == 1 {
+			sym.Bits[i/64] |= uint64(v&1) << (i % 64)
+		} else {
+			sym.Coeff[i] = v
+		}
+	}
+	if c.bits == 1 {
+		sym.Bits = make([]uint64, packedWords(c.K))
+	} else {
+		sym.Coeff = make([]uint16, c.K)
+	}
+
+	switch enc {
+	case EncodingSeed:
+		if len(coeffs) != 4 {
+			return Symbol{}, ErrBadPacket
+		}
+		sym.Seed, sym.Seeded = binary.BigEndian.Uint32(coeffs), true
+		sym.Coeff, sym.Bits = seedCoeffs(sym.Seed, c)
+	case EncodingSparse:
+		if len(coeffs) < 2 {
+			return Symbol{}, ErrBadPacket
+		}
+		n := int(binary.BigEndian.Uint16(coeffs))
+		if len(coeffs) != 2+n*(2+elemBytes(c.GF)) {
+			return Symbol{}, ErrBadPacket
+		}
+		vals := coeffs[2+2*n:]
+		prev := -1
+		for j := 0; j < n; j++ {
+			i := int(binary.BigEndian.Uint16(coeffs[2+2*j:]))
+			if i <= prev || i >= c.K {
+				return Symbol{}, fmt.Errorf("%w: sparse index %d out of order or range", ErrBadPacket, i)
+			}
+			prev = i
+			v := uint16(1)
+			if w := elemBytes(c.GF); w > 0 {
+				v = elem(vals[j*w:])
+			}
+			if v == 0 || int(v) >= c.size {
+				return Symbol{}, fmt.Errorf("%w: sparse value %d", ErrBadPacket, v)
+			}
+			set(i, v)
+		}
+	case EncodingDense:
+		if len(coeffs) != c.coeffBytes() {
+			return Symbol{}, ErrBadPacket
+		}
+		for i := 0; i < c.K; i++ {
+			if c.bits == 1 {
+				set(i, uint16(coeffs[i/8]>>(i%8)))
+			} else {
+				v := elem(coeffs[i*elemBytes(c.GF):])
+				if int(v) >= c.size {
+					return Symbol{}, fmt.Errorf("%w: coefficient %d", ErrBadPacket, v)
+				}
+				set(i, v)
+			}
+		}
+	default:
+		return Symbol{}, fmt.Errorf("%w: unknown coefficient encoding %d", ErrBadPacket, enc)
+	}
+	return sym, nil
+}
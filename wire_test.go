@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// wireSymbols returns one dense, one seeded and one sparse symbol over c.
+func wireSymbols(t testing.TB, c *Coding, rng *rand.Rand) map[CoeffEncoding]Symbol {
+	t.Helper()
+	_, src := encodeFile(c, rng)
+	seeded := mixSeeded(src, c, rng)
+	seeded.expand(c)
+	return map[CoeffEncoding]Symbol{
+		EncodingDense:  mixSymbol(src, c, rng),
+		EncodingSeed:   seeded,
+		EncodingSparse: mixSparse(src, c, sparsity{degree: 2}, rng),
+	}
+}
+
+func sameCoeffs(a, b *Symbol, k int) bool {
+	for i := 0; i < k; i++ {
+		if a.coeffAt(i) != b.coeffAt(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWireRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, bits := range []int{1, 8, 16} {
+		c, err := NewCoding(bits, 0, 200, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for enc, sym := range wireSymbols(t, c, rng) {
+			sym.Gen = 7
+			pkt, err := Marshal(&sym, c)
+			if err != nil {
+				t.Fatalf("GF(2^%d) %v: Marshal: %v", bits, enc, err)
+			}
+			got, h, err := Unmarshal(pkt, c.GF)
+			if err != nil {
+				t.Fatalf("GF(2^%d) %v: Unmarshal: %v", bits, enc, err)
+			}
+			want := Header{Field: bits, Poly: c.poly, Encoding: enc, Gen: 7, K: c.K, Size: c.Size}
+			if h != want {
+				t.Errorf("GF(2^%d) %v: header %+v, want %+v", bits, enc, h, want)
+			}
+			if got.Gen != sym.Gen || got.Seeded != sym.Seeded || !bytes.Equal(got.Data, sym.Data) || !sameCoeffs(&got, &sym, c.K) {
+				t.Errorf("GF(2^%d) %v: symbol changed in the round trip", bits, enc)
+			}
+			// The simulator charges unserialized dense symbols what they cost on the wire
+			if n := len(pkt) - wireHeaderSize - wireCRCSize - c.Size; enc == EncodingDense && (Msg{Sym: sym}).headerSize(c) != n {
+				t.Errorf("GF(2^%d): dense header counted as %d B, packet has %d B", bits, (Msg{Sym: sym}).headerSize(c), n)
+			}
+		}
+	}
+}
+
+// TestWireGeometryFromHeader checks that a receiver takes k and the
+// symbol size from the packet, not from its own configuration.
+func TestWireGeometryFromHeader(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	c, _ := NewCoding(8, 0, 5, 24)
+	sym := wireSymbols(t, c, rng)[EncodingDense]
+	pkt, err := Marshal(&sym, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := NewCoding(8, 0, 64, 1024)
+	got, h, err := Unmarshal(pkt, other.GF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.K != 5 || h.Size != 24 || len(got.Coeff) != 5 || len(got.Data) != 24 {
+		t.Errorf("got k=%d, %d B; want k=5, 24 B", h.K, h.Size)
+	}
+	if _, err := unmarshalFor(pkt, other, 1); !errors.Is(err, ErrBadPacket) {
+		t.Errorf("unmarshalFor with a different geometry: err = %v, want ErrBadPacket", err)
+	}
+}
+
+func TestWireRejects(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	c, _ := NewCoding(8, 0x11d, 16, 32)
+	sym := wireSymbols(t, c, rng)[EncodingDense]
+	pkt, err := Marshal(&sym, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same width, different polynomial: the coefficients mean something else
+	aes, _ := NewGF(8, 0x11b)
+	if _, _, err := Unmarshal(pkt, aes); !errors.Is(err, ErrBadPacket) {
+		t.Errorf("other polynomial: err = %v, want ErrBadPacket", err)
+	}
+	gf16, _ := NewGF(16, 0)
+	if _, _, err := Unmarshal(pkt, gf16); !errors.Is(err, ErrBadPacket) {
+		t.Errorf("other field: err = %v, want ErrBadPacket", err)
+	}
+
+	flipped := append([]byte(nil), pkt...)
+	flipped[wireHeaderSize+3] ^= 1
+	if _, _, err := Unmarshal(flipped, c.GF); !errors.Is(err, ErrBadChecksum) {
+		t.Errorf("flipped coefficient bit: err = %v, want ErrBadChecksum", err)
+	}
+	for n := 0; n < len(pkt); n++ {
+		if _, _, err := Unmarshal(pkt[:n], c.GF); err == nil {
+			t.Fatalf("truncated to %d of %d bytes: no error", n, len(pkt))
+		}
+	}
+}
+
+// FuzzUnmarshal checks that no input makes Unmarshal panic, and that
+// whatever it accepts survives a Marshal round trip.
+func FuzzUnmarshal(f *testing.F) {
+	rng := rand.New(rand.NewSource(4))
+	fields := map[int]*Coding{}
+	for _, bits := range []int{1, 8, 16} {
+		c, err := NewCoding(bits, 0, 12, 16)
+		if err != nil {
+			f.Fatal(err)
+		}
+		fields[bits] = c
+		for _, sym := range wireSymbols(f, c, rng) {
+			pkt, err := Marshal(&sym, c)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(pkt)
+		}
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, c := range fields {
+			sym, h, err := Unmarshal(b, c.GF)
+			if err != nil {
+				continue
+			}
+			hc := &Coding{GF: c.GF, K: h.K, Size: h.Size}
+			pkt, err := Marshal(&sym, hc)
+			if err != nil {
+				t.Fatalf("accepted packet does not marshal again: %v", err)
+			}
+			again, h2, err := Unmarshal(pkt, c.GF)
+			if err != nil {
+				t.Fatalf("re-marshaled packet rejected: %v", err)
+			}
+			if h2.K != h.K || h2.Size != h.Size || again.Gen != sym.Gen ||
+				!bytes.Equal(again.Data, sym.Data) || !sameCoeffs(&again, &sym, h.K) {
+				t.Fatalf("symbol changed in the round trip")
+			}
+		}
+	})
+}